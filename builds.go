@@ -0,0 +1,42 @@
+package main
+
+import "github.com/canonical/edgex-snap-info/snapinfo"
+
+// Indicators of the Launchpad build states, shown in the Build column
+const (
+	buildSucceeded = "✅"
+	buildRunning   = "🔨"
+	buildPending   = "⏳"
+	buildFailed    = "❌"
+	// buildMissing marks a released revision without any build record, a publishing anomaly
+	buildMissing = "⚠️"
+)
+
+// buildIndicators are the indicators of the known build statuses
+var buildIndicators = map[snapinfo.BuildStatus]string{
+	snapinfo.BuildSucceeded: buildSucceeded,
+	snapinfo.BuildRunning:   buildRunning,
+	snapinfo.BuildPending:   buildPending,
+	snapinfo.BuildFailed:    buildFailed,
+	snapinfo.BuildMissing:   buildMissing,
+}
+
+// buildIndicator returns the indicator of a build status, or "" when unknown
+func buildIndicator(status snapinfo.BuildStatus) string {
+	return buildIndicators[status]
+}
+
+// buildRank ranks an indicator by the rank of its build status
+func buildRank(indicator string) int {
+	for status, i := range buildIndicators {
+		if i == indicator {
+			return status.Rank()
+		}
+	}
+	return snapinfo.BuildUnknown.Rank()
+}
+
+// built reports whether the revision of the row has a successful build
+func (r row) built() bool {
+	return r.Build == buildSucceeded
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/canonical/edgex-snap-info/snapinfo"
+)
+
+// cacheTransport stores successful GET responses on disk and serves them
+// without hitting the network for as long as they are newer than the TTL.
+// Once expired, a response with an ETag is revalidated with a conditional request,
+// which GitHub doesn't count against the rate limit when answered with 304 Not Modified.
+type cacheTransport struct {
+	next http.RoundTripper
+	dir  string
+	ttl  time.Duration
+}
+
+type cacheEntry struct {
+	URL        string          `json:"url"`
+	Time       time.Time       `json:"time"`
+	StatusCode int             `json:"status_code"`
+	Header     http.Header     `json:"header"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// defaultCacheDir returns the directory used for caching when none is configured
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "edgex-snap-info")
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.next.RoundTrip(req)
+	}
+
+	file := filepath.Join(t.dir, cacheKey(req))
+	entry, err := readCacheEntry(file)
+	if err == nil && time.Since(entry.Time) < t.ttl {
+		with("url", req.URL.Redacted()).infof("📦 Using cached response for %s from %s", req.URL.Redacted(), entry.Time.Format(time.Stamp))
+		return entry.response(req), nil
+	}
+
+	var etag string
+	if entry != nil {
+		etag = entry.Header.Get("ETag")
+	}
+	if etag != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", etag)
+	}
+	res, err := t.next.RoundTrip(req)
+	if err == nil && etag != "" && res.StatusCode == http.StatusNotModified {
+		snapinfo.CloseBody(res)
+		with("url", req.URL.Redacted()).infof("📦 Using cached response for %s from %s, not modified since", req.URL.Redacted(), entry.Time.Format(time.Stamp))
+		// the fresh headers tell the current rate limit
+		for k, v := range res.Header {
+			entry.Header[k] = v
+		}
+		entry.Time = time.Now()
+		if err := writeCacheEntry(file, entry); err != nil {
+			with("url", req.URL.Redacted()).errorf("Error caching response for %s: %s", req.URL.Redacted(), err)
+		}
+		return entry.response(req), nil
+	}
+	if err != nil || res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res, err
+	}
+
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	entry = &cacheEntry{
+		URL:        req.URL.Redacted(),
+		Time:       time.Now(),
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+		Body:       body,
+	}
+	if err := writeCacheEntry(file, entry); err != nil {
+		with("url", req.URL.Redacted()).errorf("Error caching response for %s: %s", req.URL.Redacted(), err)
+	}
+
+	return res, nil
+}
+
+var unsafeChars = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+// cacheKey derives the cache file name from the endpoint and snap in the URL,
+// followed by a hash of the full URL and the headers that alter the response
+func cacheKey(req *http.Request) string {
+	h := sha256.New()
+	fmt.Fprintln(h, req.URL.String())
+	for _, k := range []string{"Snap-Device-Series", "Snap-Device-Architecture"} {
+		fmt.Fprintln(h, k, req.Header.Get(k))
+	}
+	name := unsafeChars.ReplaceAllString(req.URL.Host+req.URL.Path, "_")
+	return strings.Trim(name, "_") + "-" + hex.EncodeToString(h.Sum(nil))[:12] + ".json"
+}
+
+func readCacheEntry(file string) (*cacheEntry, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func writeCacheEntry(file string, entry *cacheEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, b, 0o644)
+}
+
+func (entry *cacheEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.StatusCode, http.StatusText(entry.StatusCode)),
+		StatusCode:    entry.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        entry.Header,
+		Body:          io.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       req,
+	}
+}
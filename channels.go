@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/canonical/edgex-snap-info/snapinfo"
+)
+
+// versionMismatches returns the channels, as track/risk, whose architectures carry different versions
+func versionMismatches(name string, info *snapinfo.SnapInfo) map[string]bool {
+	versions := make(map[string]map[string][]string) // channel -> version -> archs
+	for _, cm := range info.ChannelMap {
+		channel := cm.Channel.Track + "/" + cm.Channel.Risk
+		if versions[channel] == nil {
+			versions[channel] = make(map[string][]string)
+		}
+		versions[channel][cm.Version] = append(versions[channel][cm.Version], cm.Channel.Architecture)
+	}
+
+	mismatches := make(map[string]bool)
+	for channel, archs := range versions {
+		if len(archs) < 2 {
+			continue
+		}
+		mismatches[channel] = true
+
+		var details []string
+		for version, a := range archs {
+			sort.Strings(a)
+			details = append(details, version+" ("+strings.Join(a, ", ")+")")
+		}
+		sort.Strings(details)
+		with("snap", name, "channel", channel).infof("⚠️ %s %s has different versions across architectures: %s", name, channel, strings.Join(details, ", "))
+	}
+	return mismatches
+}
+
+// staleStables returns the track/arch pairs whose stable release is older than the candidate release
+// by more than the threshold, i.e. whose candidate was never promoted
+func staleStables(name string, info *snapinfo.SnapInfo, threshold time.Duration) []string {
+	type key struct{ track, arch string }
+	stable := make(map[key]time.Time)
+	candidate := make(map[key]time.Time)
+	var keys []key
+	for _, cm := range info.ChannelMap {
+		k := key{cm.Channel.Track, cm.Channel.Architecture}
+		switch strings.ToLower(cm.Channel.Risk) {
+		case "stable":
+			stable[k] = cm.Channel.ReleasedAt
+			keys = append(keys, k)
+		case "candidate":
+			candidate[k] = cm.Channel.ReleasedAt
+		}
+	}
+
+	var stale []string
+	for _, k := range keys {
+		c, found := candidate[k]
+		if !found {
+			continue
+		}
+		if lag := c.Sub(stable[k]); lag > threshold {
+			with("snap", name, "track", k.track, "arch", k.arch).infof("🟠 %s %s/stable on %s was released %s before the candidate", name, k.track, k.arch, formatAge(lag))
+			stale = append(stale, fmt.Sprintf("%s/stable on %s is %s older than candidate", k.track, k.arch, formatAge(lag)))
+		}
+	}
+	return stale
+}
+
+// summarizeByChannel collapses the rows of each channel into one, listing the architectures it covers.
+// The revision is only kept when uniform, and the build shows the least successful one.
+func summarizeByChannel(results []snapResult) []snapResult {
+	summarized := make([]snapResult, len(results))
+	for i, res := range results {
+		summarized[i] = res
+		summarized[i].Rows = nil
+		index := make(map[string]int)
+		var archs, versions [][]string
+		for _, r := range res.Rows {
+			j, found := index[r.Channel]
+			if !found {
+				j = len(summarized[i].Rows)
+				index[r.Channel] = j
+				summarized[i].Rows = append(summarized[i].Rows, r)
+				archs = append(archs, nil)
+				versions = append(versions, nil)
+			}
+			s := &summarized[i].Rows[j]
+			archs[j] = append(archs[j], r.Arch)
+			if !oneOf(r.Version, versions[j]) {
+				versions[j] = append(versions[j], r.Version)
+			}
+			if s.Revision != r.Revision {
+				s.Revision = 0
+			}
+			if buildRank(r.Build) < buildRank(s.Build) {
+				s.Build = r.Build
+			}
+			if r.Date != nil && (s.Date == nil || r.Date.After(*s.Date)) {
+				s.Date = r.Date
+			}
+		}
+		for j := range summarized[i].Rows {
+			s := &summarized[i].Rows[j]
+			sort.Strings(archs[j])
+			noun := "arches"
+			if len(archs[j]) == 1 {
+				noun = "arch"
+			}
+			s.Arch = fmt.Sprintf("%d %s (%s)", len(archs[j]), noun, strings.Join(archs[j], ", "))
+			s.Version = strings.Join(versions[j], ", ")
+			s.Mismatch = len(versions[j]) > 1
+		}
+	}
+	return summarized
+}
+
+// renderDiff prints, per snap, track and architecture, how far the revision of one risk
+// lags behind another, e.g. stable behind candidate
+func renderDiff(w io.Writer, results []snapResult, from, to string) error {
+	for _, res := range results {
+		type key struct{ track, arch string }
+		byRisk := make(map[key]map[string]row)
+		var keys []key
+		for _, r := range res.Rows {
+			k := key{r.track, r.Arch}
+			if byRisk[k] == nil {
+				byRisk[k] = make(map[string]row)
+				keys = append(keys, k)
+			}
+			byRisk[k][strings.ToLower(r.risk)] = r
+		}
+
+		for _, k := range keys {
+			a, foundA := byRisk[k][from]
+			b, foundB := byRisk[k][to]
+			prefix := fmt.Sprintf("%s %s %s:", res.Name, k.track, k.arch)
+			var line string
+			switch {
+			case !foundA && !foundB:
+				continue
+			case !foundA:
+				line = fmt.Sprintf("%s %s not released, %s rev %d (%s)", prefix, from, to, b.Revision, b.Version)
+			case !foundB:
+				line = fmt.Sprintf("%s %s rev %d (%s), %s not released", prefix, from, a.Revision, a.Version, to)
+			default:
+				line = fmt.Sprintf("%s %s rev %d (%s) → %s rev %d (%s), %s",
+					prefix, from, a.Revision, a.Version, to, b.Revision, b.Version, revisionGap(a.Revision, b.Revision))
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// revisionGap describes how far revision a is from revision b
+func revisionGap(a, b uint) string {
+	switch {
+	case a < b:
+		return fmt.Sprintf("%d behind", b-a)
+	case a > b:
+		return fmt.Sprintf("%d ahead", a-b)
+	default:
+		return "up to date"
+	}
+}
+
+// parseDiff parses a riskA:riskB pair
+func parseDiff(s string) (from, to string, err error) {
+	from, to, found := strings.Cut(strings.ToLower(s), ":")
+	if !found || from == "" || to == "" {
+		return "", "", fmt.Errorf("%q is not in riskA:riskB form", s)
+	}
+	return from, to, nil
+}
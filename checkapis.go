@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/canonical/edgex-snap-info/snapinfo"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// checkSnap is a known public snap, queried to check the snap store
+const checkSnap = "edgexfoundry"
+
+// apiCheck is the outcome of checking a service
+type apiCheck struct {
+	service string
+	latency time.Duration
+	details string
+	err     error
+}
+
+// checkAPIs checks the reachability of the services, and the rate limit of GitHub, with one request each.
+// It reports whether all services are reachable.
+func checkAPIs(ctx context.Context, w io.Writer, client *snapinfo.Client) bool {
+	timed := func(service string, check func() (string, error)) apiCheck {
+		start := time.Now()
+		details, err := check()
+		return apiCheck{service: service, latency: time.Since(start), details: details, err: err}
+	}
+
+	checks := []apiCheck{
+		timed(serviceSnapStore, func() (string, error) {
+			details := "anonymous"
+			if client.StoreAuth != "" {
+				details = "authenticated"
+			}
+			_, err := client.QuerySnapStore(ctx, checkSnap)
+			return details, err
+		}),
+		timed(serviceLaunchpad, func() (string, error) {
+			return "", client.PingLaunchpad(ctx)
+		}),
+		timed(serviceGithub, func() (string, error) {
+			limit, err := client.QueryGithubRateLimit(ctx)
+			if err != nil {
+				return "", err
+			}
+			details := "anonymous"
+			if client.GithubToken != "" {
+				details = "authenticated"
+			}
+			return fmt.Sprintf("%s, %d/%d requests left, resets at %s", details, limit.Remaining, limit.Limit, limit.Reset.Format("15:04")), nil
+		}),
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(w)
+	t.SetStyle(table.StyleLight)
+	t.AppendHeader(table.Row{"Service", "Status", "Latency", "Details"})
+	ok := true
+	for _, c := range checks {
+		status, details := "✅", c.details
+		if c.err != nil {
+			ok = false
+			status, details = "❌", c.err.Error()
+		}
+		t.AppendRow(table.Row{c.service, status, c.latency.Round(time.Millisecond), details})
+	}
+	t.Render()
+	return ok
+}
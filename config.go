@@ -0,0 +1,502 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/BurntSushi/toml"
+	"github.com/canonical/edgex-snap-info/snapinfo"
+	"gopkg.in/yaml.v3"
+)
+
+// errConfigNotFound is returned when a remote config doesn't exist
+var errConfigNotFound = errors.New("not found")
+
+type config struct {
+	// Include lists other config files or URLs whose snaps are merged, in order, before the snaps of this config
+	Include []string              `json:"include,omitempty" yaml:"include,omitempty" toml:"include,omitempty"`
+	Snaps   map[string]SnapConfig `json:"snaps" yaml:"snaps" toml:"snaps"`
+}
+
+// SnapConfig is the config of a single snap; all fields but GithubRepo are optional
+type SnapConfig struct {
+	GithubRepo string `json:"githubRepo" yaml:"githubRepo" toml:"githubRepo"`
+	// DisplayName is shown instead of the snap name
+	DisplayName string `json:"displayName,omitempty" yaml:"displayName,omitempty" toml:"displayName,omitempty"`
+	// Owner is the team responsible for the snap
+	Owner string `json:"owner,omitempty" yaml:"owner,omitempty" toml:"owner,omitempty"`
+	// Docs are links to the documentation of the snap
+	Docs []string `json:"docs,omitempty" yaml:"docs,omitempty" toml:"docs,omitempty"`
+	// GithubAPI is the base URL of the GitHub API hosting the repo, e.g. of GitHub Enterprise
+	GithubAPI string `json:"githubApi,omitempty" yaml:"githubApi,omitempty" toml:"githubApi,omitempty"`
+	// LaunchpadOwner is the person or team owning the snap recipe on Launchpad
+	LaunchpadOwner string `json:"launchpadOwner" yaml:"launchpadOwner" toml:"launchpadOwner"`
+	// WorkflowName is the name of the GitHub workflow running the tests
+	WorkflowName string `json:"workflowName" yaml:"workflowName" toml:"workflowName"`
+	// WorkflowFile is the file of the GitHub workflow running the tests, e.g. snap-testing.yml,
+	// only querying its runs instead of filtering the runs by WorkflowName
+	WorkflowFile string `json:"workflowFile,omitempty" yaml:"workflowFile,omitempty" toml:"workflowFile,omitempty"`
+	// Publisher is the username of the store account expected to publish the snap
+	Publisher string `json:"publisher,omitempty" yaml:"publisher,omitempty" toml:"publisher,omitempty"`
+	// Disabled skips the snap, e.g. when it is deprecated
+	Disabled bool `json:"disabled,omitempty" yaml:"disabled,omitempty" toml:"disabled,omitempty"`
+}
+
+const defaultLaunchpadOwner = "canonical-edgex"
+
+// snap returns the snap to collect with the library
+func (s SnapConfig) snap(name string) snapinfo.Snap {
+	return snapinfo.Snap{
+		Name:           name,
+		LaunchpadOwner: s.LaunchpadOwner,
+		GithubRepo:     s.GithubRepo,
+		GithubAPI:      s.GithubAPI,
+		WorkflowName:   s.WorkflowName,
+		WorkflowFile:   s.WorkflowFile,
+	}
+}
+
+const (
+	configJSON = "json"
+	configYAML = "yaml"
+	configTOML = "toml"
+)
+
+// loadConfig loads the config from a URL, a local path, or stdin for -, along with its includes
+func loadConfig(ctx context.Context, client *http.Client, confFile string) (*config, error) {
+	c, err := readConfig(ctx, client, confFile, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+
+	for name, snap := range c.Snaps {
+		if snap.LaunchpadOwner == "" {
+			snap.LaunchpadOwner = defaultLaunchpadOwner
+			c.Snaps[name] = snap
+		}
+	}
+
+	return c, nil
+}
+
+// readConfig reads the config and its includes, merging the snaps of later configs over earlier ones.
+// The including configs are tracked to detect cycles.
+func readConfig(ctx context.Context, client *http.Client, confFile string, including map[string]bool) (*config, error) {
+	if including[confFile] {
+		return nil, fmt.Errorf("config %s includes itself", confFile)
+	}
+	including[confFile] = true
+	defer delete(including, confFile)
+
+	data, contentType, err := readConfigData(ctx, client, confFile)
+	if err != nil {
+		return nil, err
+	}
+	c, err := decodeConfig(data, configFormat(confFile, contentType, data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", confFile, err)
+	}
+
+	merged := &config{Snaps: make(map[string]SnapConfig)}
+	for _, include := range c.Include {
+		inc, err := readConfig(ctx, client, resolveInclude(confFile, include), including)
+		if err != nil {
+			return nil, err
+		}
+		for name, snap := range inc.Snaps {
+			merged.Snaps[name] = snap
+		}
+	}
+	for name, snap := range c.Snaps {
+		merged.Snaps[name] = snap
+	}
+	return merged, nil
+}
+
+// readConfigData reads a config file from a URL, a local path, or stdin for -
+func readConfigData(ctx context.Context, client *http.Client, confFile string) (data []byte, contentType string, err error) {
+	if confFile == "-" {
+		infof("Reading config file from stdin")
+		data, err = io.ReadAll(os.Stdin)
+		return data, "", err
+	}
+
+	if strings.HasPrefix(confFile, "http") {
+		infof("Fetching config file from: %s", confFile)
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, confFile, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			return nil, "", fmt.Errorf("network error: %w", err)
+		}
+		defer snapinfo.CloseBody(res)
+
+		switch {
+		case res.StatusCode == http.StatusNotFound:
+			return nil, "", fmt.Errorf("%s %w (%s)", confFile, errConfigNotFound, res.Status)
+		case res.StatusCode < 200 || res.StatusCode >= 300:
+			return nil, "", fmt.Errorf("unexpected response status fetching %s: %s", confFile, res.Status)
+		}
+
+		data, err = io.ReadAll(res.Body)
+		return data, res.Header.Get("Content-Type"), err
+	}
+
+	infof("Reading local config file from: %s", confFile)
+	data, err = os.ReadFile(confFile)
+	return data, "", err
+}
+
+// resolveInclude resolves an include relative to the URL or directory of the including config
+func resolveInclude(confFile, include string) string {
+	if strings.HasPrefix(include, "http") || filepath.IsAbs(include) || confFile == "-" {
+		return include
+	}
+	if strings.HasPrefix(confFile, "http") {
+		base, err := url.Parse(confFile)
+		if err != nil {
+			return include
+		}
+		ref, err := url.Parse(include)
+		if err != nil {
+			return include
+		}
+		return base.ResolveReference(ref).String()
+	}
+	return filepath.Join(filepath.Dir(confFile), include)
+}
+
+// addSnap adds a snap to the config, or overrides the GitHub repo of an existing one
+func (c *config) addSnap(name, githubRepo string) {
+	if c.Snaps == nil {
+		c.Snaps = make(map[string]SnapConfig)
+	}
+	snap := c.Snaps[name]
+	snap.GithubRepo = githubRepo
+	if snap.LaunchpadOwner == "" {
+		snap.LaunchpadOwner = defaultLaunchpadOwner
+	}
+	c.Snaps[name] = snap
+}
+
+// discoverSnaps returns the config of the snaps with a recipe owned by the given Launchpad person or team,
+// along with the ad hoc snaps. The snaps listed in the given config keep their settings,
+// while the GitHub repo of the others is inferred from the repository of the recipe, if hosted on GitHub.
+func discoverSnaps(ctx context.Context, client *snapinfo.Client, owner string, conf *config, adHoc map[string]string) (*config, error) {
+	recipes, err := client.QueryLaunchpadRecipes(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	discovered := &config{Snaps: make(map[string]SnapConfig)}
+	var noRepo []string
+	for _, r := range recipes {
+		// the builds are queried by recipe name, which must be the snap name
+		if r.StoreName != r.Name {
+			with("recipe", r.Name, "snap", r.StoreName).debugf("Skipping the recipe %s, not named after its snap %q", r.Name, r.StoreName)
+			continue
+		}
+		if snap, found := conf.Snaps[r.Name]; found {
+			discovered.Snaps[r.Name] = snap
+			continue
+		}
+		snap := SnapConfig{LaunchpadOwner: owner, GithubRepo: githubRepoOf(r.GitRepositoryURL)}
+		if snap.GithubRepo == "" {
+			noRepo = append(noRepo, r.Name)
+		}
+		discovered.Snaps[r.Name] = snap
+	}
+	for name := range adHoc {
+		discovered.Snaps[name] = conf.Snaps[name]
+	}
+	infof("Discovered %d snaps of %s on Launchpad", len(discovered.Snaps)-len(adHoc), owner)
+	if len(noRepo) > 0 {
+		sort.Strings(noRepo)
+		infof("No GitHub repo known for %s, their tests aren't checked", strings.Join(noRepo, ", "))
+	}
+	return discovered, nil
+}
+
+// githubRepoOf returns the owner/name of a GitHub repository URL, or "" for other URLs
+func githubRepoOf(repoURL string) string {
+	u, err := url.Parse(repoURL)
+	if err != nil || !strings.EqualFold(u.Host, "github.com") {
+		return ""
+	}
+	repo := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	if !githubRepoPattern.MatchString(repo) {
+		return ""
+	}
+	return repo
+}
+
+// configHint returns advice for when the config could not be found or fetched, or "" for other errors
+func configHint(err error, confFile string) string {
+	var dnsErr *net.DNSError
+	if !errors.Is(err, errConfigNotFound) && !errors.Is(err, fs.ErrNotExist) &&
+		!errors.Is(err, syscall.ECONNREFUSED) && !errors.As(err, &dnsErr) {
+		return ""
+	}
+	if confFile == configURL {
+		return fmt.Sprintf("The default config could not be fetched from %s; use --conf to load a local copy, e.g. --conf=./config.json", configURL)
+	}
+	return fmt.Sprintf("Could not find or reach %s; check the path, or use --conf with a local file, e.g. --conf=./config.json", confFile)
+}
+
+// unknownSnapMessage tells that a selected snap is missing from the given source of snaps, e.g. "in the config",
+// suggesting the known snaps with a close name, or else listing all of them
+func unknownSnapMessage(name, source string, c *config) string {
+	var known, close []string
+	for k := range c.Snaps {
+		known = append(known, k)
+		if strings.Contains(k, name) || strings.Contains(name, k) || editDistance(k, name) <= max(2, len(name)/4) {
+			close = append(close, k)
+		}
+	}
+	sort.Strings(known)
+	sort.Strings(close)
+
+	msg := fmt.Sprintf("Snap %s is not %s", name, source)
+	switch {
+	case len(close) > 0:
+		msg += fmt.Sprintf(", did you mean %s?", strings.Join(close, " or "))
+	case len(known) > 0:
+		msg += fmt.Sprintf("; the known snaps are %s.", strings.Join(known, ", "))
+	default:
+		msg += "."
+	}
+	return msg + " Use --github-repo to add it"
+}
+
+// editDistance returns the number of single-character insertions, deletions or substitutions between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// parseGithubRepos parses a comma-separated list of snap=owner/repo pairs
+func parseGithubRepos(list string) (map[string]string, error) {
+	repos := make(map[string]string)
+	for _, pair := range strings.Split(list, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, repo, found := strings.Cut(pair, "=")
+		if !found || name == "" {
+			return nil, fmt.Errorf("%q is not in snap=owner/repo form", pair)
+		}
+		repos[strings.ToLower(name)] = repo
+	}
+	return repos, nil
+}
+
+// parseGithubTokens parses a comma-separated list of host=token pairs
+func parseGithubTokens(list string) (map[string]string, error) {
+	tokens := make(map[string]string)
+	for _, pair := range strings.Split(list, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		host, token, found := strings.Cut(pair, "=")
+		if !found || host == "" || token == "" {
+			// the token isn't included in the error, to not leak it
+			return nil, fmt.Errorf("%q is not in host=token form", host)
+		}
+		tokens[strings.ToLower(host)] = token
+	}
+	return tokens, nil
+}
+
+// configFormat detects the format of the config from the file extension,
+// the content type of a remote file, or else the content itself
+func configFormat(path, contentType string, data []byte) string {
+	if u, err := url.Parse(path); err == nil && u.Scheme != "" {
+		path = u.Path
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return configJSON
+	case ".yaml", ".yml":
+		return configYAML
+	case ".toml":
+		return configTOML
+	}
+
+	if strings.Contains(contentType, "json") {
+		return configJSON
+	}
+	if strings.Contains(contentType, "yaml") {
+		return configYAML
+	}
+	if strings.Contains(contentType, "toml") {
+		return configTOML
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return configJSON
+	}
+	if looksLikeTOML(data) {
+		return configTOML
+	}
+	return configYAML
+}
+
+// tomlLinePattern matches a TOML table header, e.g. [snaps.edgex-ui], or key/value pair, neither of which is valid YAML
+var tomlLinePattern = regexp.MustCompile(`^(\[[\w."-]+\]|[\w"-]+\s*=)`)
+
+// looksLikeTOML reports whether the first line with content, other than a comment, is TOML
+func looksLikeTOML(data []byte) bool {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return tomlLinePattern.MatchString(line)
+	}
+	return false
+}
+
+// decodeConfig decodes the config in the given format, rejecting unknown fields
+func decodeConfig(data []byte, format string) (*config, error) {
+	var c config
+	switch format {
+	case configYAML:
+		d := yaml.NewDecoder(bytes.NewReader(data))
+		d.KnownFields(true)
+		if err := d.Decode(&c); err != nil {
+			return nil, err
+		}
+	case configTOML:
+		md, err := toml.NewDecoder(bytes.NewReader(data)).Decode(&c)
+		if err != nil {
+			return nil, err
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			var keys []string
+			for _, k := range undecoded {
+				keys = append(keys, k.String())
+			}
+			return nil, fmt.Errorf("unknown fields: %s", strings.Join(keys, ", "))
+		}
+	default:
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&c); err != nil {
+			return nil, err
+		}
+		// unlike the YAML and TOML decoders, the JSON one keeps the last of duplicate keys
+		if dups := jsonDuplicateSnaps(data); len(dups) > 0 {
+			return nil, fmt.Errorf("invalid config:\n  %s", strings.Join(dups, "\n  "))
+		}
+	}
+	return &c, nil
+}
+
+// jsonDuplicateSnaps returns the problems of the snaps listed more than once in a valid JSON config
+func jsonDuplicateSnaps(data []byte) []string {
+	d := json.NewDecoder(bytes.NewReader(data))
+	if t, err := d.Token(); err != nil || t != json.Delim('{') {
+		return nil
+	}
+	var problems []string
+	for d.More() {
+		key, err := d.Token()
+		if err != nil {
+			return problems
+		}
+		if key != "snaps" {
+			var skip json.RawMessage
+			if err := d.Decode(&skip); err != nil {
+				return problems
+			}
+			continue
+		}
+		if t, err := d.Token(); err != nil || t != json.Delim('{') {
+			continue
+		}
+		seen := make(map[string]int)
+		for d.More() {
+			name, err := d.Token()
+			if err != nil {
+				return problems
+			}
+			if seen[name.(string)]++; seen[name.(string)] == 2 {
+				problems = append(problems, fmt.Sprintf("%s: listed more than once", name))
+			}
+			var skip json.RawMessage
+			if err := d.Decode(&skip); err != nil {
+				return problems
+			}
+		}
+		// the closing brace of the snaps
+		if _, err := d.Token(); err != nil {
+			return problems
+		}
+	}
+	return problems
+}
+
+var githubRepoPattern = regexp.MustCompile(`^[\w.-]+/[\w.-]+$`)
+
+// validate checks the config entries and returns an error listing all invalid snaps,
+// including the snaps listed twice with names differing only in case
+func (c *config) validate() error {
+	var problems []string
+	names := make(map[string][]string)
+	for name, snap := range c.Snaps {
+		names[strings.ToLower(name)] = append(names[strings.ToLower(name)], name)
+		switch {
+		case snap.GithubRepo == "":
+			problems = append(problems, fmt.Sprintf("%s: missing githubRepo", name))
+		case !githubRepoPattern.MatchString(snap.GithubRepo):
+			problems = append(problems, fmt.Sprintf("%s: githubRepo %q is not in owner/name form", name, snap.GithubRepo))
+		}
+	}
+	for _, dups := range names {
+		if len(dups) > 1 {
+			sort.Strings(dups)
+			problems = append(problems, fmt.Sprintf("%s: listed more than once", strings.Join(dups, ", ")))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("invalid config:\n  %s", strings.Join(problems, "\n  "))
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   string
+		// wantErr is a substring of the expected error, or "" for none
+		wantErr string
+	}{
+		{
+			name:   "json",
+			format: configJSON,
+			data:   `{"snaps": {"edgex-ui": {"githubRepo": "edgexfoundry/edgex-ui-go"}}}`,
+		},
+		{
+			name:    "json unknown field",
+			format:  configJSON,
+			data:    `{"snaps": {"edgex-ui": {"GithubRepos": "edgexfoundry/edgex-ui-go"}}}`,
+			wantErr: `unknown field "GithubRepos"`,
+		},
+		{
+			name:    "json unknown top-level field",
+			format:  configJSON,
+			data:    `{"snap": {}}`,
+			wantErr: `unknown field "snap"`,
+		},
+		{
+			name:    "json malformed",
+			format:  configJSON,
+			data:    `{"snaps": {"edgex-ui": `,
+			wantErr: "unexpected EOF",
+		},
+		{
+			name:    "json duplicate snap",
+			format:  configJSON,
+			data:    `{"snaps": {"edgex-ui": {"githubRepo": "a/b"}, "edgex-cli": {"githubRepo": "a/c"}, "edgex-ui": {"githubRepo": "a/d"}}}`,
+			wantErr: "edgex-ui: listed more than once",
+		},
+		{
+			name:   "yaml",
+			format: configYAML,
+			data:   "snaps:\n  edgex-ui:\n    githubRepo: edgexfoundry/edgex-ui-go\n",
+		},
+		{
+			name:    "yaml unknown field",
+			format:  configYAML,
+			data:    "snaps:\n  edgex-ui:\n    githubrepo: edgexfoundry/edgex-ui-go\n",
+			wantErr: "field githubrepo not found",
+		},
+		{
+			name:    "yaml duplicate snap",
+			format:  configYAML,
+			data:    "snaps:\n  edgex-ui:\n    githubRepo: a/b\n  edgex-ui:\n    githubRepo: a/c\n",
+			wantErr: "already defined",
+		},
+		{
+			name:   "toml",
+			format: configTOML,
+			data:   "[snaps.edgex-ui]\ngithubRepo = \"edgexfoundry/edgex-ui-go\"\n",
+		},
+		{
+			name:    "toml unknown field",
+			format:  configTOML,
+			data:    "[snaps.edgex-ui]\ngithub = \"edgexfoundry/edgex-ui-go\"\n",
+			wantErr: "unknown fields: snaps.edgex-ui.github",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := decodeConfig([]byte(tt.data), tt.format)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if got := c.Snaps["edgex-ui"].GithubRepo; got != "edgexfoundry/edgex-ui-go" {
+					t.Errorf("githubRepo is %q", got)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJSONDuplicateSnaps(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{"none", `{"snaps": {"a": {}, "b": {}}}`, nil},
+		{"twice", `{"snaps": {"a": {}, "b": {}, "a": {}}}`, []string{"a: listed more than once"}},
+		{"thrice reported once", `{"snaps": {"a": {}, "a": {}, "a": {}}}`, []string{"a: listed more than once"}},
+		{"after other fields", `{"include": ["x.json"], "snaps": {"a": {"docs": ["a"]}, "a": {}}}`, []string{"a: listed more than once"}},
+		{"same name in other snaps", `{"snaps": {"a": {"githubRepo": "x/a"}, "b": {"githubRepo": "x/a"}}}`, nil},
+		{"not an object", `[]`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jsonDuplicateSnaps([]byte(tt.data)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		// want is the list of problems, or nil for a valid config
+		want []string
+	}{
+		{
+			name: "valid",
+			data: `{"snaps": {"edgex-ui": {"githubRepo": "edgexfoundry/edgex-ui-go"}}}`,
+		},
+		{
+			name: "missing githubRepo",
+			data: `{"snaps": {"edgex-ui": {}}}`,
+			want: []string{"edgex-ui: missing githubRepo"},
+		},
+		{
+			name: "githubRepo not in owner/name form",
+			data: `{"snaps": {"edgex-ui": {"githubRepo": "https://github.com/edgexfoundry/edgex-ui-go"}}}`,
+			want: []string{`edgex-ui: githubRepo "https://github.com/edgexfoundry/edgex-ui-go" is not in owner/name form`},
+		},
+		{
+			name: "names differing in case",
+			data: `{"snaps": {"edgex-ui": {"githubRepo": "a/b"}, "EdgeX-UI": {"githubRepo": "a/b"}}}`,
+			want: []string{"EdgeX-UI, edgex-ui: listed more than once"},
+		},
+		{
+			name: "aggregated",
+			data: `{"snaps": {"edgex-ui": {"githubRepo": "edgex-ui-go"}, "edgex-cli": {}, "edgex-go": {"githubRepo": "edgexfoundry/edgex-go"}}}`,
+			want: []string{
+				"edgex-cli: missing githubRepo",
+				`edgex-ui: githubRepo "edgex-ui-go" is not in owner/name form`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := decodeConfig([]byte(tt.data), configJSON)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = c.validate()
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+			want := "invalid config:\n  " + strings.Join(tt.want, "\n  ")
+			if err == nil || err.Error() != want {
+				t.Errorf("got error %v, want %q", err, want)
+			}
+		})
+	}
+}
+
+func TestUnknownSnapMessage(t *testing.T) {
+	c := &config{Snaps: map[string]SnapConfig{"edgex-ui": {}, "edgex-device-gpio": {}, "edgexfoundry": {}}}
+	tests := []struct {
+		name string
+		conf *config
+		want string
+	}{
+		{"edgex-uj", c, "Snap edgex-uj is not in the config, did you mean edgex-ui? Use --github-repo to add it"},
+		{"device-gpio", c, "Snap device-gpio is not in the config, did you mean edgex-device-gpio? Use --github-repo to add it"},
+		{"foo", c, "Snap foo is not in the config; the known snaps are edgex-device-gpio, edgex-ui, edgexfoundry. Use --github-repo to add it"},
+		{"foo", &config{}, "Snap foo is not in the config. Use --github-repo to add it"},
+	}
+	for _, tt := range tests {
+		if got := unknownSnapMessage(tt.name, "in the config", tt.conf); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
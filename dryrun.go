@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// dryRunTransport logs the requests instead of performing them,
+// responding with empty JSON objects so that querying carries on
+type dryRunTransport struct{}
+
+func (dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// logged even with -q, as it is the purpose of the dry run
+	with("method", req.Method, "url", req.URL.Redacted()).logf(levelQuiet, slog.LevelInfo, "🔎 %s %s", req.Method, req.URL.Redacted())
+	body := []byte("{}")
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/canonical/edgex-snap-info/snapinfo"
+)
+
+// dumpTransport writes the raw responses to the requests of each snap to a directory,
+// in files named by snap and service, e.g. for filing upstream bug reports or building test fixtures.
+// The responses of further pages are numbered, e.g. edgex-ui.launchpad.2.json.
+type dumpTransport struct {
+	next http.RoundTripper
+	dir  string
+
+	mu    sync.Mutex
+	pages map[snapinfo.Query]int
+}
+
+func (t *dumpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	query, ok := snapinfo.QueryFromContext(req.Context())
+	res, err := t.next.RoundTrip(req)
+	if !ok || err != nil {
+		return res, err
+	}
+
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	file := filepath.Join(t.dir, t.fileName(query))
+	if err := os.WriteFile(file, body, 0o644); err != nil {
+		with("url", req.URL.Redacted()).errorf("Error dumping the response for %s: %s", req.URL.Redacted(), err)
+	} else {
+		with("url", req.URL.Redacted(), "file", file).debugf("Dumped the response for %s to %s", req.URL.Redacted(), file)
+	}
+	return res, nil
+}
+
+// fileName returns the name of the file for the next response of the query
+func (t *dumpTransport) fileName(query snapinfo.Query) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pages == nil {
+		t.pages = make(map[snapinfo.Query]int)
+	}
+	t.pages[query]++
+	name := strings.Trim(unsafeChars.ReplaceAllString(query.Snap, "_"), "_") + "." + query.Service
+	if page := t.pages[query]; page > 1 {
+		name += fmt.Sprintf(".%d", page)
+	}
+	return name + ".json"
+}
+
+// reset restarts the numbering of the pages, so that each run overwrites the files of the previous one
+func (t *dumpTransport) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pages = nil
+}
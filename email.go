@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// emailer sends the report by email through an SMTP server
+type emailer struct {
+	// addr is the host:port of the SMTP server
+	addr     string
+	from     string
+	to       []string
+	username string
+	password string
+	// always sends the report after every run, also when no snap failed or recovered
+	always bool
+}
+
+func (e *emailer) enabled() bool {
+	return e.addr != ""
+}
+
+// parseAddresses parses a comma-separated list of email addresses
+func parseAddresses(list string) []string {
+	var addrs []string
+	for _, addr := range strings.Split(list, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// emailSubject summarizes the failures and recoveries
+func emailSubject(failed, recovered []snapResult) string {
+	switch {
+	case len(failed) > 0 && len(recovered) > 0:
+		return fmt.Sprintf("EdgeX snaps: %d with failures, %d recovered", len(failed), len(recovered))
+	case len(failed) > 0:
+		return fmt.Sprintf("EdgeX snaps: %d with failures", len(failed))
+	case len(recovered) > 0:
+		return fmt.Sprintf("EdgeX snaps: %d recovered", len(recovered))
+	}
+	return "EdgeX snaps: all healthy"
+}
+
+// send emails the HTML report of the results, with a subject summarizing the failures and recoveries,
+// or else the current failures
+func (e *emailer) send(results, failed, recovered []snapResult, opts renderOptions) error {
+	if len(failed) == 0 && len(recovered) == 0 {
+		failed = failures(results)
+	}
+	var body bytes.Buffer
+	if err := renderHTML(&body, results, opts); err != nil {
+		return err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", emailSubject(failed, recovered))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	msg.Write(bytes.ReplaceAll(body.Bytes(), []byte("\n"), []byte("\r\n")))
+
+	var auth smtp.Auth
+	if e.username != "" {
+		host, _, err := net.SplitHostPort(e.addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", e.username, e.password, host)
+	}
+	if err := smtp.SendMail(e.addr, auth, e.from, e.to, msg.Bytes()); err != nil {
+		return err
+	}
+	infof("📧 Emailed the report to %s", strings.Join(e.to, ", "))
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// filters selects the channel-map entries to be shown.
+// An empty set matches everything, but for the risks which default to the released ones.
+type filters struct {
+	tracks set
+	archs  set
+	risks  set
+	// defaultTrack only matches the default track of each snap
+	defaultTrack bool
+	// prereleases also matches the prerelease risks, beta and edge, when the risks aren't set
+	prereleases bool
+}
+
+// releasedRisks are the risks matched by default, leaving out the prereleases
+var releasedRisks = set{"stable": true, "candidate": true}
+
+// match reports whether the filters match the channel, given the default track of the snap
+func (f filters) match(track, risk, arch, defaultTrack string) bool {
+	if f.defaultTrack && !strings.EqualFold(track, defaultTrack) {
+		return false
+	}
+	return f.tracks.match(track) && f.archs.match(arch) && f.riskSet().match(risk)
+}
+
+// riskSet returns the risks to match, or an empty set for all
+func (f filters) riskSet() set {
+	if len(f.risks) == 0 && !f.prereleases {
+		return releasedRisks
+	}
+	return f.risks
+}
+
+func (f filters) String() string {
+	var s []string
+	if f.defaultTrack {
+		s = append(s, "track=default")
+	}
+	if len(f.tracks) > 0 {
+		s = append(s, "track="+f.tracks.String())
+	}
+	if len(f.archs) > 0 {
+		s = append(s, "arch="+f.archs.String())
+	}
+	if risks := f.riskSet(); len(risks) > 0 {
+		s = append(s, "risk="+risks.String())
+	}
+	return strings.Join(s, " ")
+}
+
+// set is a case-insensitive set of strings
+type set map[string]bool
+
+// parseSet parses a comma-separated list of values
+func parseSet(list string) set {
+	s := make(set)
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			s[strings.ToLower(v)] = true
+		}
+	}
+	return s
+}
+
+func (s set) match(v string) bool {
+	return len(s) == 0 || s[strings.ToLower(v)]
+}
+
+// subsetOf reports whether the set is non-empty and all its values are keys of the map
+func (s set) subsetOf(m map[string]string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for v := range s {
+		if _, found := m[v]; !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (s set) String() string {
+	var values []string
+	for v := range s {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return strings.Join(values, ",")
+}
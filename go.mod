@@ -1,11 +1,17 @@
 module github.com/canonical/edgex-snap-info
 
-go 1.18
+go 1.21
 
-require github.com/jedib0t/go-pretty/v6 v6.4.2
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/jedib0t/go-pretty/v6 v6.5.9
+	golang.org/x/sync v0.11.0
+	golang.org/x/term v0.29.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
-	github.com/mattn/go-runewidth v0.0.13 // indirect
+	github.com/mattn/go-runewidth v0.0.15 // indirect
 	github.com/rivo/uniseg v0.2.0 // indirect
-	golang.org/x/sys v0.0.0-20190412213103-97732733099d // indirect
+	golang.org/x/sys v0.30.0 // indirect
 )
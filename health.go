@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/canonical/edgex-snap-info/snapinfo"
+)
+
+// Exit codes reflecting the overall health of the snaps.
+// When several problems are found, the most severe one determines the code.
+const (
+	exitHealthy = 0
+	// exitFatal is returned by log.Fatal, e.g. when the config file cannot be loaded
+	exitFatal = 1
+	// exitUsage is returned by the flag package for invalid flags
+	exitUsage         = 2
+	exitMissingBuilds = 3
+	exitTestFailures  = 4
+	exitQueryErrors   = 5
+)
+
+// Problems that may fail the run, for --fail-on
+const (
+	failOnTestFailure  = "test-failure"
+	failOnMissingBuild = "missing-build"
+	failOnAnyError     = "any-error"
+	failOnNone         = "none"
+)
+
+var failOnValues = []string{failOnTestFailure, failOnMissingBuild, failOnAnyError, failOnNone}
+
+// parseFailOn parses a comma-separated list of the problems that fail the run
+func parseFailOn(list string) (set, error) {
+	failOn := parseSet(list)
+	for v := range failOn {
+		if !oneOf(v, failOnValues) {
+			return nil, fmt.Errorf("unsupported value %q, expected one of: %s", v, strings.Join(failOnValues, ", "))
+		}
+	}
+	if failOn[failOnNone] && len(failOn) > 1 {
+		return nil, fmt.Errorf("%s can't be combined with other values", failOnNone)
+	}
+	return failOn, nil
+}
+
+// testsPassed reports whether the tests ran and none failed, or the snap has no tests to check
+func (res *snapResult) testsPassed() bool {
+	return res.NoTests || (res.TestsTotal > 0 && res.TestsFailed == 0)
+}
+
+// missingBuilds reports whether any of the released revisions lacks a successful build
+func (res *snapResult) missingBuilds() bool {
+	for _, r := range res.Rows {
+		if !r.built() {
+			return true
+		}
+	}
+	return false
+}
+
+// healthy reports whether the snap was fully queried, its tests passed with none still running,
+// and all its released revisions have successful builds
+func (res *snapResult) healthy() bool {
+	return res.Error == "" && res.testsPassed() && res.TestsRunning == 0 && !res.missingBuilds()
+}
+
+// problems returns the snaps that aren't healthy
+func problems(results []snapResult) []snapResult {
+	var unhealthy []snapResult
+	for _, res := range results {
+		if !res.healthy() {
+			unhealthy = append(unhealthy, res)
+		}
+	}
+	return unhealthy
+}
+
+// healthSummary counts the snaps by health, e.g. for the table footer
+func healthSummary(results []snapResult) []string {
+	var healthy, testFailures, missingBuilds int
+	for _, res := range results {
+		if res.TestsFailed > 0 {
+			testFailures++
+		}
+		if res.missingBuilds() {
+			missingBuilds++
+		}
+		if res.healthy() {
+			healthy++
+		}
+	}
+	return []string{
+		fmt.Sprintf("%d snaps", len(results)),
+		fmt.Sprintf("🟢 %d healthy", healthy),
+		fmt.Sprintf("🔴 %d test failures", testFailures),
+		fmt.Sprintf("❌ %d missing builds", missingBuilds),
+	}
+}
+
+// errorSummary groups the errors querying the services by service and kind, naming the snaps,
+// e.g. "github: 3 snaps rate-limited (a, b, c); launchpad: 1 snap timed out (d)", or "" without errors
+func errorSummary(results []snapResult) string {
+	type key struct{ service, kind string }
+	snaps := make(map[key][]string)
+	for _, res := range results {
+		for _, e := range res.queryErrors {
+			k := key{e.service, e.kind}
+			if !oneOf(res.Name, snaps[k]) {
+				snaps[k] = append(snaps[k], res.Name)
+			}
+		}
+	}
+
+	var services []string
+	for _, service := range []string{serviceSnapStore, serviceLaunchpad, serviceGithub} {
+		var kinds []string
+		for _, kind := range []string{errorTimeout, errorRateLimited, errorFailed} {
+			names := snaps[key{service, kind}]
+			if len(names) == 0 {
+				continue
+			}
+			noun := "snaps"
+			if len(names) == 1 {
+				noun = "snap"
+			}
+			sort.Strings(names)
+			kinds = append(kinds, fmt.Sprintf("%d %s %s (%s)", len(names), noun, kind, strings.Join(names, ", ")))
+		}
+		if len(kinds) > 0 {
+			services = append(services, service+": "+strings.Join(kinds, ", "))
+		}
+	}
+	return strings.Join(services, "; ")
+}
+
+// checkArchBuilt checks that every snap has a stable release for the given architecture
+// with a successful build, whichever channels are shown, logging the snaps that don't.
+// It returns exitMissingBuilds when a build is missing, or else exitQueryErrors
+// when the builds of a snap are unknown because the store or Launchpad couldn't be queried.
+func checkArchBuilt(results []snapResult, arch string) int {
+	code := exitHealthy
+	for _, res := range results {
+		if res.failed(serviceSnapStore) || res.failed(serviceLaunchpad) {
+			with("snap", res.Name, "arch", arch).errorf("❌ %s: unknown whether its stable release for %s is built, the services couldn't be queried", res.Name, arch)
+			code = max(code, exitQueryErrors)
+			continue
+		}
+		released := false
+		for _, ch := range res.channels {
+			if !strings.EqualFold(ch.Channel.Architecture, arch) || !strings.EqualFold(ch.Channel.Risk, "stable") {
+				continue
+			}
+			released = true
+			if ch.Build != snapinfo.BuildSucceeded {
+				channel := ch.Channel.Track + "/" + ch.Channel.Risk
+				with("snap", res.Name, "channel", channel, "arch", ch.Channel.Architecture, "revision", ch.Revision).errorf("❌ %s %s revision %d has no successful %s build", res.Name, channel, ch.Revision, ch.Channel.Architecture)
+				code = max(code, exitMissingBuilds)
+			}
+		}
+		if !released {
+			with("snap", res.Name, "arch", arch).errorf("❌ %s has no stable release for %s", res.Name, arch)
+			code = max(code, exitMissingBuilds)
+		}
+	}
+	return code
+}
+
+// exitCode computes the exit code from the results of all snaps, only considering the given problems.
+// The tests and builds of a snap with errors aren't considered, since they may be incomplete.
+func exitCode(results []snapResult, interrupted bool, failOn set) int {
+	code := exitHealthy
+	if interrupted && failOn[failOnAnyError] {
+		code = exitQueryErrors
+	}
+	for _, res := range results {
+		switch {
+		case res.Error != "":
+			if failOn[failOnAnyError] {
+				code = max(code, exitQueryErrors)
+			}
+		case !res.testsPassed() && failOn[failOnTestFailure]:
+			code = max(code, exitTestFailures)
+		case res.missingBuilds() && failOn[failOnMissingBuild]:
+			code = max(code, exitMissingBuilds)
+		}
+	}
+	return code
+}
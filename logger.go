@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type logLevel int
+
+const (
+	// levelQuiet only logs errors
+	levelQuiet logLevel = iota
+	// levelNormal also logs the progress
+	levelNormal
+	// levelVerbose also logs the requests and their timings
+	levelVerbose
+)
+
+var verbosity = levelNormal
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+var logFormats = []string{logFormatText, logFormatJSON}
+
+// jsonLogger emits structured logs when set, instead of the human-friendly text lines
+var jsonLogger *slog.Logger
+
+func setLogFormat(format string) {
+	if format == logFormatJSON {
+		jsonLogger = slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+}
+
+// logEntry carries the structured fields of a log line, as key-value pairs.
+// The fields are only emitted by the JSON logger; the text lines already mention them.
+type logEntry struct {
+	fields []any
+}
+
+func with(fields ...any) logEntry {
+	return logEntry{fields: fields}
+}
+
+func (e logEntry) errorf(format string, a ...any) {
+	e.logf(levelQuiet, slog.LevelError, format, a...)
+}
+
+func (e logEntry) infof(format string, a ...any) {
+	e.logf(levelNormal, slog.LevelInfo, format, a...)
+}
+
+func (e logEntry) debugf(format string, a ...any) {
+	e.logf(levelVerbose, slog.LevelDebug, format, a...)
+}
+
+func (e logEntry) logf(min logLevel, level slog.Level, format string, a ...any) {
+	if verbosity < min {
+		return
+	}
+	if jsonLogger != nil {
+		jsonLogger.Log(context.Background(), level, fmt.Sprintf(format, a...), e.fields...)
+		return
+	}
+	log.Printf(format, a...)
+}
+
+func errorf(format string, a ...any) {
+	logEntry{}.errorf(format, a...)
+}
+
+func infof(format string, a ...any) {
+	logEntry{}.infof(format, a...)
+}
+
+func debugf(format string, a ...any) {
+	logEntry{}.debugf(format, a...)
+}
+
+// loggingTransport logs each request and its response time in verbose mode
+type loggingTransport struct {
+	next http.RoundTripper
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := t.next.RoundTrip(req)
+	elapsed := time.Since(start)
+	l := with("url", req.URL.Redacted(), "duration_ms", elapsed.Milliseconds())
+	if err != nil {
+		l.debugf("%s %s: %s after %s", req.Method, req.URL.Redacted(), err, elapsed.Round(time.Millisecond))
+	} else {
+		l.fields = append(l.fields, "status", res.StatusCode)
+		l.debugf("%s %s: %s in %s", req.Method, req.URL.Redacted(), res.Status, elapsed.Round(time.Millisecond))
+	}
+	return res, err
+}
+
+// latencies accumulates the time spent querying each service
+type latencies struct {
+	mu    sync.Mutex
+	total map[string]time.Duration
+}
+
+// record logs the latency of a service for a snap in verbose mode and adds it to the total
+func (l *latencies) record(snap, service string, d time.Duration) {
+	with("snap", snap, "service", service, "duration_ms", d.Milliseconds()).debugf("⏱️ %s %s %s", snap, service, d.Round(time.Millisecond))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.total == nil {
+		l.total = make(map[string]time.Duration)
+	}
+	l.total[service] += d
+}
+
+// summary logs the total time spent per service in verbose mode
+func (l *latencies) summary() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var s []string
+	for _, service := range []string{serviceSnapStore, serviceLaunchpad, serviceGithub} {
+		if d, found := l.total[service]; found {
+			s = append(s, fmt.Sprintf("%s %s", service, d.Round(time.Millisecond)))
+		}
+	}
+	if len(s) > 0 {
+		debugf("⏱️ Total time per service: %s", strings.Join(s, ", "))
+	}
+}
@@ -1,249 +1,811 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
-	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/canonical/edgex-snap-info/snapinfo"
+	"golang.org/x/term"
 )
 
 const (
 	configURL = "https://raw.githubusercontent.com/canonical/edgex-snap-info/main/config.json"
 )
 
+// Names of the queried services, used in errors and logs
+const (
+	serviceSnapStore = snapinfo.ServiceSnapStore
+	serviceLaunchpad = snapinfo.ServiceLaunchpad
+	serviceGithub    = snapinfo.ServiceGithub
+)
+
 func main() {
-	confFile := flag.String("conf", configURL, "URL or local path to config file")
-	snapName := flag.String("snap", "", "Get info for a single snap only")
+	confFile := flag.String("conf", configURL, "URL or local path to config file, or - to read it from stdin")
+	snapNames := flag.String("snap", "", "Comma-separated list of snaps to get info for (default all in config)")
+	ignore := flag.String("ignore", "", "Comma-separated list of snaps to skip, e.g. deprecated ones, in addition to those disabled in the config")
+	discoverLaunchpad := flag.String("discover-launchpad", "", "Check all snaps with a recipe owned by this Launchpad person or team, e.g. canonical-edgex, instead of those listed in the config; the config still provides their settings")
+	githubRepos := flag.String("github-repo", "", "Comma-separated list of snap=owner/repo pairs, adding snaps that aren't in the config")
+	format := flag.String("format", formatTable, "Output format: "+strings.Join(formats, ", "))
+	output := flag.String("output", "", "Write the output to this file instead of stdout")
+	maxWidth := flag.String("max-width", "", "Cap the width of the table to this number of characters, wrapping the cells, or auto for the width of the terminal")
+	noColor := flag.Bool("no-color", false, "Disable colors in the table, also when NO_COLOR is set or the output is not a terminal")
+	watch := flag.Duration("watch", 0, "Refresh the output at this interval, e.g. 5m, until interrupted")
+	diff := flag.String("diff", "", "Instead of the table, show how far one risk lags behind another, e.g. stable:candidate")
+	showBase := flag.Bool("show-base", false, "Show the base snap of each revision, e.g. core22")
+	showConfinement := flag.Bool("show-confinement", false, "Show the confinement and grade of each revision, e.g. to spot devmode releases")
+	onlyProblems := flag.Bool("only-problems", false, "Only show the snaps with test failures, missing builds, running tests or errors")
+	showPublisher := flag.Bool("show-publisher", false, "Show the publisher of each snap, flagging those not published by the account set in the config")
+	showSize := flag.Bool("show-size", false, "Show the download size of each revision")
+	absoluteDates := flag.Bool("absolute-dates", false, "Show the release dates in addition to their age")
+	sortBy := flag.String("sort", sortChannel, "Sort the channels of each snap by: "+strings.Join(sortKeys, ", "))
+	cacheTTL := flag.Duration("cache-ttl", 0, "Reuse cached API responses newer than this duration, e.g. 10m (default disabled)")
+	cacheDir := flag.String("cache-dir", defaultCacheDir(), "Directory for cached API responses")
+	dumpDir := flag.String("dump-dir", "", "Write the raw responses of the services to this directory, in files named by snap and service, e.g. edgex-ui.snapstore.json")
+	noCache := flag.Bool("no-cache", false, "Bypass reading and writing the cache")
+	concurrency := flag.Int("concurrency", 4, "Number of snaps to query in parallel")
+	track := flag.String("track", "", "Comma-separated list of channel tracks to show (default all)")
+	defaultTrackOnly := flag.Bool("default-track-only", false, "Only show the default track of each snap, as set in the store, hiding legacy tracks")
+	arch := flag.String("arch", "", "Comma-separated list of architectures to show (default all)")
+	risk := flag.String("risk", "", "Comma-separated list of channel risk levels to show (default stable and candidate, or all with --include-prereleases)")
+	includePrereleases := flag.Bool("include-prereleases", false, "Also show the beta and edge channels, unless --risk or --channel-order is set")
+	channelOrder := flag.String("channel-order", "", "Comma-separated list of the channel risk levels to show, in this order, e.g. stable,candidate,beta,edge")
+	series := flag.String("series", "16", "Device series sent to the snap store")
+	deviceArch := flag.String("device-arch", "", "Device architecture sent to the snap store, to only get channels of that architecture (default --arch when it names a single one)")
+	launchpadPages := flag.Int("launchpad-pages", 5, "Maximum number of Launchpad build pages to fetch per snap")
+	storeAuth := flag.String("store-auth", "", "File with snap store credentials exported by snapcraft export-login, to query private snaps (default $SNAPCRAFT_STORE_CREDENTIALS)")
+	githubToken := flag.String("github-token", "", "GitHub token for authenticated API calls (default $GITHUB_TOKEN)")
+	workflow := flag.String("workflow", "Snap Testing", "Name of the GitHub workflow running the tests, for snaps that don't set workflowName")
+	githubEvent := flag.String("github-event", "pull_request", "Only count the GitHub runs triggered by this event, e.g. push, or all when empty")
+	githubBranch := flag.String("github-branch", "", "Only count the GitHub runs on this branch, e.g. main (default all)")
+	deadline := flag.Duration("deadline", 0, "Maximum duration of the whole run, e.g. 2m, after which the remaining snaps are skipped and the partial results shown")
+	timeout := flag.Duration("timeout", 30*time.Second, "Timeout for each HTTP request, including reading the response and retries")
+	snapStoreAPI := flag.String("snapstore-api", envOr("SNAPSTORE_API", snapinfo.DefaultSnapStoreURL), "Base URL of the snap store API")
+	launchpadAPI := flag.String("launchpad-api", envOr("LAUNCHPAD_API", snapinfo.DefaultLaunchpadURL), "Base URL of the Launchpad API")
+	githubRuns := flag.Int("github-runs", 10, "Number of most recent GitHub runs checked per snap; more than 100 are fetched over several pages")
+	githubHostTokens := flag.String("github-host-token", "", "Comma-separated list of host=token pairs for other GitHub hosts, e.g. GitHub Enterprise, set per snap with githubApi in the config (default $GITHUB_HOST_TOKENS)")
+	githubAPI := flag.String("github-api", envOr("GITHUB_API", snapinfo.DefaultGithubURL), "Base URL of the GitHub API")
+	smtpAddr := flag.String("smtp", "", "Email the report through this SMTP server, in host:port form, authenticating with $SMTP_USERNAME and $SMTP_PASSWORD when set")
+	emailFrom := flag.String("email-from", "", "Sender address of the emails")
+	emailTo := flag.String("email-to", "", "Comma-separated list of recipient addresses of the emails")
+	emailAlways := flag.Bool("email-always", false, "Email the report after every run, instead of only when snaps fail or recover")
+	validateOnly := flag.Bool("validate-only", false, "Only load and validate the config file, including its includes, then exit without querying the services")
+	checkAPIsOnly := flag.Bool("check-apis", false, "Check the reachability and authentication of the snap store, Launchpad and GitHub, and the GitHub rate limit, then exit")
+	slackWebhook := flag.String("slack-webhook", "", "Post a summary of the snaps with test failures or missing builds to this Slack incoming webhook URL")
+	stateFile := flag.String("state-file", "", "Persist the status of each snap to this file and only notify when a snap becomes unhealthy or recovers")
+	proxy := flag.String("proxy", "", "Proxy URL for all requests (default from $HTTPS_PROXY, $HTTP_PROXY and $NO_PROXY)")
+	retries := flag.Int("retries", 3, "Maximum number of attempts for each HTTP request")
+	maxTotalRetries := flag.Int("max-total-retries", 0, "Maximum number of retries across all requests of a run, after which failed requests are no longer retried (default no limit)")
+	retryJitter := flag.Float64("retry-jitter", 0.5, "Fraction of each retry backoff that is randomized, from 0 to 1, to spread the retries of requests failing together")
+	var verbose, quiet bool
+	flag.BoolVar(&verbose, "v", false, "Log the requests and their timings")
+	flag.BoolVar(&verbose, "verbose", false, "Log the requests and their timings")
+	flag.BoolVar(&quiet, "q", false, "Only log errors")
+	flag.BoolVar(&quiet, "quiet", false, "Only log errors")
+	logFormat := flag.String("log-format", logFormatText, "Log format: "+strings.Join(logFormats, ", "))
+	failOnList := flag.String("fail-on", strings.Join([]string{failOnTestFailure, failOnMissingBuild, failOnAnyError}, ","), "Comma-separated list of the problems that exit with a non-zero code: "+strings.Join(failOnValues, ", "))
+	staleStable := flag.Duration("stale-stable", 0, "Warn when a stable release is older than the candidate release of the same track and architecture by more than this duration, e.g. 720h")
+	detectRollback := flag.Bool("detect-rollback", false, "Warn when a channel's revision is lower than the highest one seen before, as recorded in --state-file")
+	sinceLastRun := flag.Bool("since-last-run", false, "Instead of the table, show the releases that changed since the last run, as recorded in --state-file")
+	buildMatrix := flag.Bool("build-matrix", false, "Instead of the table, show a grid of the revisions of the --arch architecture per risk, with the status of their builds")
+	summaryByChannel := flag.Bool("summary-by-channel", false, "Show one row per channel, listing the architectures it covers, instead of one row per architecture")
+	requireArch := flag.String("require-arch", "", "Exit with a non-zero code unless every snap has a successful build of its stable revisions for this architecture, e.g. arm64")
+	pprofAddr := flag.String("pprof", "", "Serve the runtime profiles of net/http/pprof on this address for the duration of the run, e.g. localhost:6060")
+	serve := flag.String("serve", "", "Instead of printing the status once, serve it as JSON at /status.json on this address, e.g. :8080")
+	dryRun := flag.Bool("dry-run", false, "Log the URLs that would be requested and exit without requesting them")
+	printVersion := flag.Bool("version", false, "Print the version and exit")
+	flag.Usage = usage
 	flag.Parse()
 
-	conf, err := loadConfig(*confFile)
+	if *printVersion {
+		fmt.Println(versionString())
+		return
+	}
+
+	// keep stdout clean for machine-readable formats
+	log.SetOutput(os.Stderr)
+	if !oneOf(*logFormat, logFormats) {
+		log.Fatalf("Unsupported log format: %s", *logFormat)
+	}
+	setLogFormat(*logFormat)
+	switch {
+	case quiet:
+		verbosity = levelQuiet
+	case verbose:
+		verbosity = levelVerbose
+	}
+	if *pprofAddr != "" {
+		startPprof(*pprofAddr)
+	}
+
+	if !oneOf(*format, formats) {
+		log.Fatalf("Unsupported output format: %s", *format)
+	}
+	if !oneOf(*sortBy, sortKeys) {
+		log.Fatalf("Unsupported sort key: %s", *sortBy)
+	}
+	failOn, err := parseFailOn(*failOnList)
 	if err != nil {
-		log.Fatalf("Error loading config file: %s", err)
+		log.Fatalf("Error parsing --fail-on: %s", err)
+	}
+	var diffFrom, diffTo string
+	if *diff != "" {
+		var err error
+		diffFrom, diffTo, err = parseDiff(*diff)
+		if err != nil {
+			log.Fatalf("Error parsing --diff: %s", err)
+		}
 	}
 
-	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
-	t.SetStyle(table.StyleColoredBright)
-	t.AppendHeader(table.Row{"Name", "Channel", "Version", "Arch", "Rev", "Date", "Build"})
+	if *githubToken == "" {
+		*githubToken = os.Getenv("GITHUB_TOKEN")
+	}
+	if *githubHostTokens == "" {
+		*githubHostTokens = os.Getenv("GITHUB_HOST_TOKENS")
+	}
+	hostTokens, err := parseGithubTokens(*githubHostTokens)
+	if err != nil {
+		log.Fatalf("Error parsing --github-host-token: %s", err)
+	}
+	if *githubToken == "" {
+		infof("No GitHub token set, falling back to anonymous access")
+	}
 
-	t.SetColumnConfigs([]table.ColumnConfig{
-		{Number: 1, AutoMerge: true},
-		{Number: 2, AutoMerge: true},
-		{Number: 3, AutoMerge: true},
-	})
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *deadline)
+		defer cancel()
+	}
 
-	for k, v := range conf.Snaps {
-		// filter by snap name
-		if *snapName != "" && k != *snapName {
-			continue
+	if *retryJitter < 0 || *retryJitter > 1 {
+		log.Fatalf("Invalid --retry-jitter %g: not between 0 and 1", *retryJitter)
+	}
+	base, err := newTransport(*proxy)
+	if err != nil {
+		log.Fatalf("Error parsing --proxy: %s", err)
+	}
+	retrying := &retryTransport{
+		next:     &loggingTransport{next: base},
+		attempts: *retries,
+		backoff:  500 * time.Millisecond,
+		jitter:   *retryJitter,
+		maxTotal: int64(*maxTotalRetries),
+	}
+	// the config is fetched for real, also in a dry run, and isn't cached
+	configClient := &http.Client{
+		Timeout:   *timeout,
+		Transport: retrying,
+	}
+
+	if *validateOnly {
+		conf, err := loadConfig(ctx, configClient, *confFile)
+		if err != nil {
+			log.Fatalf("Error loading config file: %s", err)
 		}
+		fmt.Printf("%s is valid, with %d snaps\n", *confFile, len(conf.Snaps))
+		return
+	}
 
-		log.Printf("⏬ %s", k)
+	adHoc, err := parseGithubRepos(*githubRepos)
+	if err != nil {
+		log.Fatalf("Error parsing --github-repo: %s", err)
+	}
+	selected := parseSet(*snapNames)
 
-		// snap store
-		info, err := querySnapStore(k)
+	// the config isn't needed when all the selected snaps are given ad hoc
+	conf := &config{}
+	if !*checkAPIsOnly && (flagSet("conf") || !selected.subsetOf(adHoc)) {
+		conf, err = loadConfig(ctx, configClient, *confFile)
 		if err != nil {
-			log.Fatalf("Error querying snap store: %s", err)
+			if hint := configHint(err, *confFile); hint != "" {
+				log.Fatalf("Error loading config file: %s\n%s", err, hint)
+			}
+			log.Fatalf("Error loading config file: %s", err)
 		}
+	}
+	for name, repo := range adHoc {
+		conf.addSnap(name, repo)
+	}
+	if err := conf.validate(); err != nil {
+		log.Fatalf("Error in --github-repo: %s", err)
+	}
+	if *discoverLaunchpad != "" {
+		lp := snapinfo.NewClient(configClient)
+		lp.LaunchpadURL = strings.TrimSuffix(*launchpadAPI, "/")
+		if *dryRun {
+			// the snaps can't be discovered without querying Launchpad, so only log the request
+			lp.HTTPClient = &http.Client{Transport: dryRunTransport{}}
+			lp.QueryLaunchpadRecipes(ctx, *discoverLaunchpad)
+			infof("Dry run, logging the requests of the configured snaps instead of those of %s", *discoverLaunchpad)
+		} else if conf, err = discoverSnaps(ctx, lp, *discoverLaunchpad, conf, adHoc); err != nil {
+			log.Fatalf("Error discovering the snaps of %s on Launchpad: %s", *discoverLaunchpad, err)
+		}
+	}
 
-		// launchpad
-		builds, err := queryLaunchpad(k)
-		if err != nil {
-			log.Fatalf("Error querying launchpad: %s", err)
+	// filter by snap name
+	ignored := parseSet(*ignore)
+	var names, skipped []string
+	for k, snap := range conf.Snaps {
+		if !selected.match(k) {
+			continue
 		}
-		revisionBuildStatus := make(map[uint]string)
-		for _, v := range builds.Entries {
-			// Setting a check mark only if we find the successful build result for a given revision.
-			// Alternative scenarios include results that have no revision number because:
-			// - build or artifact upload has failed (an actual failure)
-			// - build is too old and not returned in the query
-			// - build or artifact upload is pending
-			if v.StoreUploadRevision != nil && v.BuildState == "Successfully built" {
-				revisionBuildStatus[*v.StoreUploadRevision] = "✅"
-			}
+		if ignored[k] || snap.Disabled {
+			skipped = append(skipped, k)
+			continue
 		}
+		names = append(names, k)
+	}
+	if len(skipped) > 0 {
+		sort.Strings(skipped)
+		infof("Skipping disabled or ignored snaps: %s", strings.Join(skipped, ", "))
+	}
+	source := "in the config"
+	if *discoverLaunchpad != "" && !*dryRun {
+		source = fmt.Sprintf("among the snaps of %s discovered on Launchpad", *discoverLaunchpad)
+	}
+	for k := range selected {
+		if _, found := conf.Snaps[k]; !found {
+			log.Fatal(unknownSnapMessage(k, source, conf))
+		}
+	}
+	sort.Strings(names)
 
-		// github
-		runs, err := queryGithub(v.GithubRepo)
-		if err != nil {
-			log.Fatalf("Error querying launchpad: %s", err)
+	risks := parseSet(*risk)
+	if *channelOrder != "" {
+		if *risk != "" {
+			log.Fatalf("--channel-order can't be combined with --risk")
 		}
-		var totalSnapRuns, failedSnapRuns uint
-		testIcon := "🔴"
-		for _, run := range runs.WorkflowRuns {
-			if run.Name == "Snap Testing" {
-				totalSnapRuns++
-			}
-			if run.Conclusion == "failure" {
-				failedSnapRuns++
-				log.Printf("🔴 %s (%s)", run.DisplayTitle, run.HTMLURL)
-			}
+		riskOrder = parseChannelOrder(*channelOrder)
+		risks = make(set)
+		for r := range riskOrder {
+			risks[r] = true
 		}
-		if totalSnapRuns == 0 { // something is not right
-			testIcon = "🟠"
-		} else if failedSnapRuns == 0 {
-			testIcon = "🟢"
+	}
+
+	if *concurrency < 1 {
+		*concurrency = 1
+	}
+
+	var transport http.RoundTripper = retrying
+	if *cacheTTL > 0 && !*noCache {
+		transport = &cacheTransport{
+			next: transport,
+			dir:  *cacheDir,
+			ttl:  *cacheTTL,
+		}
+	}
+	var dump *dumpTransport
+	if *dumpDir != "" {
+		if err := os.MkdirAll(*dumpDir, 0o755); err != nil {
+			log.Fatalf("Error creating --dump-dir: %s", err)
+		}
+		dump = &dumpTransport{next: transport, dir: *dumpDir}
+		transport = dump
+	}
+	if *dryRun {
+		transport = dryRunTransport{}
+	}
+	client := snapinfo.NewClient(&http.Client{
+		Timeout:   *timeout,
+		Transport: transport,
+	})
+	client.SnapStoreURL = strings.TrimSuffix(*snapStoreAPI, "/")
+	client.LaunchpadURL = strings.TrimSuffix(*launchpadAPI, "/")
+	client.GithubURL = strings.TrimSuffix(*githubAPI, "/")
+	client.Series = *series
+	client.DeviceArch = *deviceArch
+	archs := parseSet(*arch)
+	if *requireArch != "" && *deviceArch != "" && !strings.EqualFold(*requireArch, *deviceArch) {
+		log.Fatalf("--require-arch=%s can't be checked with --device-arch=%s, the store only returns the channels of the device architecture", *requireArch, *deviceArch)
+	}
+	if len(archs) == 1 && *deviceArch == "" && (*requireArch == "" || archs[strings.ToLower(*requireArch)]) {
+		// let the store only return the channels of the single architecture shown
+		for a := range archs {
+			client.DeviceArch = a
+		}
+	}
+	client.GithubToken = *githubToken
+	client.GithubTokens = hostTokens
+	client.GithubRuns = *githubRuns
+	if client.StoreAuth, err = storeAuthorization(*storeAuth); err != nil {
+		log.Fatalf("Error reading the snap store credentials: %s", err)
+	}
+	client.GithubEvent = *githubEvent
+	client.GithubBranch = *githubBranch
+	client.LaunchpadPages = *launchpadPages
+
+	if *checkAPIsOnly {
+		// for real, bypassing the cache and the retries to measure the latencies
+		check := *client
+		check.HTTPClient = &http.Client{
+			Timeout:   *timeout,
+			Transport: &loggingTransport{next: base},
+		}
+		if !checkAPIs(ctx, os.Stdout, &check) {
+			os.Exit(exitQueryErrors)
 		}
+		return
+	}
+	c := &collector{
+		client:      client,
+		concurrency: *concurrency,
+		workflow:    *workflow,
+		staleStable: *staleStable,
+		retrying:    retrying,
+		dump:        dump,
+		filters: filters{
+			tracks:       parseSet(*track),
+			archs:        archs,
+			risks:        risks,
+			defaultTrack: *defaultTrackOnly,
+			// a diff of a prerelease needs its channels
+			prereleases: *includePrereleases || (*diff != "" && !(releasedRisks[diffFrom] && releasedRisks[diffTo])),
+		},
+	}
 
-		// fill the table
-		for _, cm := range info.ChannelMap {
-			t.AppendRow(table.Row{
-				k,
-				cm.Channel.Track + "/" + cm.Channel.Risk,
-				cm.Version,
-				cm.Channel.Architecture,
-				cm.Revision,
-				cm.Channel.ReleasedAt.Format(time.Stamp),
-				revisionBuildStatus[cm.Revision],
-			}, table.RowConfig{AutoMerge: true})
-		}
-		t.AppendRow(table.Row{
-			fmt.Sprintf("%s failed %d/%d", testIcon, failedSnapRuns, totalSnapRuns),
-			"", "", "", "", "", "",
-		}, table.RowConfig{AutoMerge: true})
-		t.AppendSeparator()
-	}
-
-	t.Render()
-}
+	if *detectRollback && *stateFile == "" {
+		log.Fatalf("--detect-rollback requires --state-file")
+	}
+	if *sinceLastRun && *stateFile == "" {
+		log.Fatalf("--since-last-run requires --state-file")
+	}
+	if *sinceLastRun && *diff != "" {
+		log.Fatalf("--since-last-run can't be combined with --diff")
+	}
+	if *buildMatrix && len(archs) != 1 {
+		log.Fatalf("--build-matrix requires --arch with a single architecture")
+	}
+	if *buildMatrix && (*diff != "" || *sinceLastRun) {
+		log.Fatalf("--build-matrix can't be combined with --diff or --since-last-run")
+	}
+	if *smtpAddr != "" && (*emailFrom == "" || *emailTo == "") {
+		log.Fatalf("--smtp requires --email-from and --email-to")
+	}
 
-type config struct {
-	Snaps map[string]struct {
-		GithubRepo string
+	n := &notifier{
+		// not through the transports of the queries, which log and dump the URLs,
+		// as the path of a Slack webhook is its secret
+		client:       &http.Client{Timeout: *timeout, Transport: base},
+		slackWebhook: *slackWebhook,
+		email: &emailer{
+			addr:     *smtpAddr,
+			from:     *emailFrom,
+			to:       parseAddresses(*emailTo),
+			username: os.Getenv("SMTP_USERNAME"),
+			password: os.Getenv("SMTP_PASSWORD"),
+			always:   *emailAlways,
+		},
+		stateFile: *stateFile,
 	}
-}
 
-func loadConfig(confFile string) (c *config, err error) {
+	opts := renderOptions{
+		// see https://no-color.org
+		color:           !*noColor && os.Getenv("NO_COLOR") == "" && *output == "" && isTerminal(os.Stdout),
+		absoluteDates:   *absoluteDates,
+		showBase:        *showBase,
+		showConfinement: *showConfinement,
+		showSize:        *showSize,
+		showPublisher:   *showPublisher,
+		onlyProblems:    *onlyProblems,
+	}
+	switch *maxWidth {
+	case "":
+	case "auto":
+		if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+			opts.maxWidth = width
+		}
+	default:
+		if opts.maxWidth, err = strconv.Atoi(*maxWidth); err != nil || opts.maxWidth < 0 {
+			log.Fatalf("Invalid --max-width %q: not a number of characters or auto", *maxWidth)
+		}
+	}
+	clearScreen := *watch > 0 && *output == "" && isTerminal(os.Stdout)
 
-	if strings.HasPrefix(confFile, "http") {
-		log.Println("Fetching config file from:", confFile)
+	if *dryRun {
+		// only the requests matter: the results of the canned responses would be false diagnostics
+		c.client.Collect(ctx, c.config(conf, names))
+		return
+	}
 
-		res, err := http.Get(confFile)
-		if err != nil {
-			return nil, err
+	if *serve != "" {
+		if err := c.serve(ctx, *serve, conf, names, *sortBy); err != nil {
+			log.Fatalf("Error serving: %s", err)
 		}
-		defer res.Body.Close()
+		return
+	}
 
-		err = json.NewDecoder(res.Body).Decode(&c)
-		if err != nil {
-			return nil, err
+	var results []snapResult
+	var interrupted bool
+	var archCode int
+	for {
+		// a single timestamp for the whole report, taken before querying
+		opts.generatedAt = time.Now().UTC().Truncate(time.Second)
+		results = c.collectAll(ctx, conf, names)
+		for _, res := range results {
+			sortRows(res.Rows, *sortBy)
 		}
-	} else {
-		log.Println("Reading local config file from:", confFile)
-		file, err := os.Open(confFile)
-		if err != nil {
-			return nil, err
+		opts.incomplete = ""
+		if skipped := len(names) - len(results); skipped > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			opts.incomplete = fmt.Sprintf("deadline exceeded, %d snaps not checked", skipped)
+		}
+
+		if *detectRollback && ctx.Err() == nil {
+			if err := checkRollbacks(*stateFile, results); err != nil {
+				errorf("Error detecting rollbacks: %s", err)
+			}
+		}
+
+		var snapshot state
+		if *sinceLastRun {
+			snapshot, err = loadState(*stateFile)
+			if err != nil {
+				log.Fatalf("Error loading state file: %s", err)
+			}
 		}
-		defer file.Close()
 
-		err = json.NewDecoder(file).Decode(&c)
+		write := func(w io.Writer) error {
+			if *sinceLastRun {
+				return snapshot.renderChanges(w, results)
+			}
+			if *diff != "" {
+				return renderDiff(w, results, diffFrom, diffTo)
+			}
+			if *buildMatrix {
+				return renderBuildMatrix(w, results, *arch)
+			}
+			if *summaryByChannel {
+				return render(w, *format, summarizeByChannel(results), opts)
+			}
+			return render(w, *format, results, opts)
+		}
+		if clearScreen {
+			fmt.Print("\033[H\033[2J")
+		}
+		if *output == "" {
+			err = write(os.Stdout)
+		} else {
+			err = writeFileAtomic(*output, write)
+		}
 		if err != nil {
-			return nil, err
+			log.Fatalf("Error rendering output: %s", err)
+		}
+		if *sinceLastRun {
+			if err := saveState(*stateFile, snapshot); err != nil {
+				errorf("Error saving state file: %s", err)
+			}
 		}
-	}
 
-	return c, nil
-}
+		if *requireArch != "" {
+			archCode = checkArchBuilt(results, *requireArch)
+		}
+		if summary := errorSummary(results); summary != "" {
+			errorf("Not all services could be queried: %s", summary)
+		}
 
-type snapInfo struct {
-	ChannelMap []struct {
-		Channel struct {
-			Architecture string
-			Track, Risk  string
-			ReleasedAt   time.Time `json:"released-at"`
+		if n.enabled() && ctx.Err() == nil {
+			if err := n.notify(ctx, results, opts); err != nil {
+				errorf("Error notifying: %s", err)
+			}
 		}
-		Revision uint
-		Version  string
-	} `json:"channel-map"`
-}
 
-func querySnapStore(snapName string) (*snapInfo, error) {
-	log.Println("Querying Snap Store info for:", snapName)
-	req, err := http.NewRequest(http.MethodGet, "https://api.snapcraft.io/v2/snaps/info/"+snapName, nil)
-	if err != nil {
-		return nil, err
+		if *watch <= 0 || ctx.Err() != nil {
+			interrupted = ctx.Err() != nil
+			break
+		}
+		select {
+		case <-ctx.Done():
+			// stopped while waiting, the last results are complete
+		case <-time.After(*watch):
+			continue
+		}
+		break
 	}
 
-	req.Header = http.Header{
-		"Snap-Device-Series": {"16"},
-	}
+	c.latencies.summary()
+	code := exitCode(results, interrupted, failOn)
+	os.Exit(max(code, archCode))
+}
 
-	client := http.Client{}
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, err
+// newTransport returns the base transport, using the given proxy URL
+// or else the proxy settings of the environment
+func newTransport(proxy string) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil {
+			return nil, err
+		}
+		t.Proxy = http.ProxyURL(u)
 	}
+	return t, nil
+}
 
-	var info snapInfo
-	err = json.NewDecoder(res.Body).Decode(&info)
-	if err != nil {
-		return nil, err
+// storeAuthorization returns the Authorization header for the snap store from the credentials file,
+// or else the environment, or "" for anonymous access
+func storeAuthorization(path string) (string, error) {
+	credentials := []byte(os.Getenv("SNAPCRAFT_STORE_CREDENTIALS"))
+	if path != "" {
+		var err error
+		credentials, err = os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
 	}
+	if len(credentials) == 0 {
+		return "", nil
+	}
+	return snapinfo.StoreAuthorization(credentials)
+}
 
-	// log.Println("Snap info:", info)
-
-	return &info, nil
+// flagSet reports whether the flag has been explicitly set
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
 
-type builds struct {
-	Entries []struct {
-		StoreUploadRevision *uint `json:"store_upload_revision"`
-		BuildState          string
+// envOr returns the value of the environment variable, or the fallback when unset
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
 	}
+	return fallback
 }
 
-func queryLaunchpad(projectName string) (*builds, error) {
-	log.Println("Querying Launchpad for:", projectName)
-	res, err := http.Get(fmt.Sprintf("https://api.launchpad.net/devel/~canonical-edgex/+snap/%s/builds?ws.size=10&direction=backwards&memo=0", projectName))
-	if err != nil {
-		return nil, err
-	}
+// collector queries the services for snaps, with settings shared across snaps
+type collector struct {
+	client      *snapinfo.Client
+	concurrency int
+	filters     filters
+	// staleStable is the maximum age of stable releases relative to candidate, or 0 to not check
+	staleStable time.Duration
+	// workflow is the name of the GitHub workflow running the tests, unless set per snap
+	workflow  string
+	latencies latencies
+	// retrying holds the retry budget, restored for each run
+	retrying *retryTransport
+	// dump writes the responses of each run, when set
+	dump *dumpTransport
+	// running serializes the runs, e.g. of concurrent requests when serving,
+	// since they share the retry budget and the dump
+	running sync.Mutex
+}
 
-	var builds builds
-	err = json.NewDecoder(res.Body).Decode(&builds)
-	if err != nil {
-		return nil, err
+// collectAll queries the given snaps with a bounded number of workers.
+// When the context is cancelled, the remaining snaps are skipped and only the collected results are returned.
+func (c *collector) collectAll(ctx context.Context, conf *config, names []string) []snapResult {
+	c.running.Lock()
+	defer c.running.Unlock()
+	c.retrying.resetRetries()
+	if c.dump != nil {
+		c.dump.reset()
+	}
+	collected, err := c.client.Collect(ctx, c.config(conf, names))
+	if errors.Is(err, context.DeadlineExceeded) {
+		errorf("Deadline exceeded, skipping the remaining snaps")
+	} else if err != nil {
+		infof("Interrupted, skipping the remaining snaps")
 	}
 
-	// log.Println("Builds:", builds)
-
-	return &builds, nil
+	results := make([]snapResult, len(collected))
+	for i, res := range collected {
+		results[i] = c.snapResult(res, conf.Snaps[res.Snap.Name])
+	}
+	return results
 }
 
-type runs struct {
-	WorkflowRuns []struct {
-		Name         string
-		Conclusion   string
-		DisplayTitle string `json:"display_title"`
-		HTMLURL      string `json:"html_url"`
-	} `json:"workflow_runs"`
-	Message string
+// config returns the library config collecting the given snaps, logging their progress
+func (c *collector) config(conf *config, names []string) snapinfo.Config {
+	cfg := snapinfo.Config{
+		Concurrency: c.concurrency,
+		Workflow:    c.workflow,
+		Started: func(snap snapinfo.Snap) {
+			with("snap", snap.Name).infof("⏬ %s", snap.Name)
+		},
+		Waiting: func(snap snapinfo.Snap, wait time.Duration, retrying bool) {
+			l := with("repo", snap.GithubRepo, "service", serviceGithub, "retry_after", wait.String())
+			if retrying {
+				l.infof("⏳ GitHub secondary rate limit hit, retrying %s in %s", snap.GithubRepo, wait)
+			} else {
+				l.infof("⏳ GitHub secondary rate limit hit, skipping %s instead of waiting %s", snap.GithubRepo, wait)
+			}
+		},
+	}
+	for _, name := range names {
+		cfg.Snaps = append(cfg.Snaps, conf.Snaps[name].snap(name))
+	}
+	return cfg
 }
 
-func queryGithub(project string) (*runs, error) {
-	log.Println("Querying Github workflow runs for:", project)
-	res, err := http.Get(fmt.Sprintf("https://api.github.com/repos/%s/actions/runs?per_page=10&event=pull_request", project))
-	if err != nil {
-		return nil, err
+// snapResult logs the problems of a collected snap and turns it into the rows shown, applying the filters
+func (c *collector) snapResult(collected snapinfo.SnapResult, snap SnapConfig) snapResult {
+	name, githubRepo := collected.Snap.Name, collected.Snap.GithubRepo
+	result := snapResult{Name: name, DisplayName: snap.DisplayName}
+	for _, service := range []string{serviceSnapStore, serviceLaunchpad, serviceGithub} {
+		if d, found := collected.Latencies[service]; found {
+			c.latencies.record(name, service, d)
+		}
 	}
+	for _, e := range collected.Errors {
+		result.queryFailed(e.Service, e.Err)
+	}
+	info := collected.Info
 
-	var r runs
-	err = json.NewDecoder(res.Body).Decode(&r)
-	if err != nil {
-		return nil, err
+	// launchpad
+	var lastBuild string
+	if builds := collected.Builds; builds != nil {
+		for _, v := range builds.Entries {
+			switch status := snapinfo.BuildStatusOf(v.BuildState); status {
+			case snapinfo.BuildFailed:
+				with("snap", name, "service", serviceLaunchpad, "status", v.BuildState, "url", v.BuildLogURL).infof("❌ %s: %s (%s)", v.Title, v.BuildState, v.BuildLogURL)
+			case snapinfo.BuildRunning, snapinfo.BuildPending:
+				with("snap", name, "service", serviceLaunchpad, "status", v.BuildState).debugf("%s %s: %s", buildIndicator(status), v.Title, v.BuildState)
+			}
+		}
+		// entries are sorted from newest to oldest
+		if len(builds.Entries) > 0 {
+			if d, ok := builds.Entries[0].Duration(); ok {
+				lastBuild = d.Round(time.Second).String()
+			}
+		}
 	}
 
-	if r.Message != "" {
-		log.Printf("🟠 %s", r.Message)
+	// github
+	if runs := collected.Runs; runs != nil {
+		for _, msg := range []string{runs.RateLimit, runs.Message} {
+			if msg != "" {
+				with("repo", githubRepo, "service", serviceGithub).infof("🟠 %s", msg)
+			}
+		}
+		tests := collected.Tests
+		for _, run := range tests.Runs {
+			if !run.Completed() {
+				with("snap", name, "service", serviceGithub, "status", run.Status, "url", run.HTMLURL).debugf("🟡 %s is %s (%s)", run.DisplayTitle, run.Status, run.HTMLURL)
+			}
+		}
+		for _, run := range tests.FailedRuns {
+			with("snap", name, "service", serviceGithub, "status", run.Conclusion, "url", run.HTMLURL, "sha", run.HeadSHA, "updated_at", run.UpdatedAt).
+				infof("🔴 %s on %s, %s ago (%s)", run.DisplayTitle, run.ShortSHA(), formatAge(time.Since(run.UpdatedAt)), run.HTMLURL)
+		}
+		testIcon := "🔴"
+		if tests.Total == 0 { // something is not right
+			testIcon = "🟠"
+			with("repo", githubRepo, "service", serviceGithub, "workflow", tests.Workflow).infof("🟠 No runs of the %q workflow found for %s", tests.Workflow, githubRepo)
+		} else if tests.Failed == 0 && tests.Running > 0 {
+			// a run mid-flight may still fail
+			testIcon = "🟡"
+		} else if tests.Failed == 0 {
+			testIcon = "🟢"
+		}
+		result.Test = fmt.Sprintf("%s failed %d/%d", testIcon, tests.Failed, tests.Total)
+		if tests.Running > 0 {
+			result.Test += fmt.Sprintf(", %d running", tests.Running)
+		}
+		result.TestsFailed, result.TestsTotal, result.TestsRunning = tests.Failed, tests.Total, tests.Running
+		result.FailedRuns = tests.FailedRuns
+	} else if githubRepo == "" {
+		result.Test = "⚪ no repo"
+		result.NoTests = true
+	}
+
+	// collect the rows
+	if info != nil {
+		result.DefaultTrack = info.DefaultTrackOrLatest()
+		result.channels = collected.Channels
+		mismatches := versionMismatches(name, info)
+		for _, ch := range collected.Channels {
+			cm := ch.ChannelMapEntry
+			if !c.filters.match(cm.Channel.Track, cm.Channel.Risk, cm.Channel.Architecture, info.DefaultTrackOrLatest()) {
+				continue
+			}
+			var date *time.Time
+			if released := cm.Channel.ReleasedAt; !released.IsZero() {
+				date = &released
+			}
+			result.Rows = append(result.Rows, row{
+				Name:        name,
+				DisplayName: snap.DisplayName,
+				Channel:     cm.Channel.Track + "/" + cm.Channel.Risk,
+				Version:     cm.Version,
+				Mismatch:    mismatches[cm.Channel.Track+"/"+cm.Channel.Risk],
+				Arch:        cm.Channel.Architecture,
+				Confinement: cm.Confinement,
+				Base:        cm.Base,
+				Grade:       cm.Grade,
+				Size:        cm.Download.Size,
+				Publisher:   info.Snap.Publisher.String(),
+				Revision:    cm.Revision,
+				Date:        date,
+				Build:       buildIndicator(ch.Build),
+				LastBuild:   lastBuild,
+				Test:        result.Test,
+				Error:       result.Error,
+				track:       cm.Channel.Track,
+				risk:        cm.Channel.Risk,
+			})
+			if ch.Build == snapinfo.BuildMissing {
+				r := result.Rows[len(result.Rows)-1]
+				with("snap", name, "track", cm.Channel.Track, "risk", cm.Channel.Risk, "arch", cm.Channel.Architecture, "revision", cm.Revision).
+					infof("⚠️ %s %s on %s has no build of revision %d", name, r.Channel, r.Arch, cm.Revision)
+			}
+		}
+		if publisher := info.Snap.Publisher.Username; snap.Publisher != "" && !strings.EqualFold(publisher, snap.Publisher) {
+			with("snap", name, "publisher", publisher, "expected", snap.Publisher).infof("⚠️ %s is published by %s instead of %s", name, info.Snap.Publisher, snap.Publisher)
+			result.addNote("published by %s instead of %s", info.Snap.Publisher, snap.Publisher)
+		}
+		if c.staleStable > 0 {
+			for _, stale := range staleStables(name, info, c.staleStable) {
+				result.addNote("%s", stale)
+			}
+		}
+		tracks := make(set)
+		for _, cm := range info.ChannelMap {
+			tracks[strings.ToLower(cm.Channel.Track)] = true
+		}
+		for t := range c.filters.tracks {
+			if !tracks[t] {
+				with("snap", name, "track", t).infof("🟠 Track %s does not exist for %s", t, name)
+			}
+		}
+		switch {
+		case len(info.ChannelMap) == 0:
+			// unlike an error, the store knows the snap but it hasn't been released to any channel
+			with("snap", name).infof("🟠 %s has no releases", name)
+			result.Note = "(no releases)"
+		case len(result.Rows) == 0:
+			with("snap", name).infof("No releases of %s match the filters", name)
+			result.Note = fmt.Sprintf("no releases for %s", c.filters)
+		}
 	}
+	return result
+}
 
-	// log.Println("Github workflow runs:", r)
+// queryFailed logs and records an error from querying the given service
+func (res *snapResult) queryFailed(service string, err error) {
+	var netErr net.Error
+	var rateLimited *snapinfo.RateLimitError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		with("snap", res.Name, "service", service, "error", err.Error()).errorf("⏱️ Timed out querying %s for %s: %s", service, res.Name, err)
+		res.addError("%s: timed out", service)
+		res.queryErrors = append(res.queryErrors, queryError{service: service, kind: errorTimeout, err: err})
+		return
+	case errors.As(err, &rateLimited):
+		res.queryErrors = append(res.queryErrors, queryError{service: service, kind: errorRateLimited, err: err})
+	default:
+		res.queryErrors = append(res.queryErrors, queryError{service: service, kind: errorFailed, err: err})
+	}
+	with("snap", res.Name, "service", service, "error", err.Error()).errorf("Error querying %s for %s: %s", service, res.Name, err)
+	res.addError("%s: %s", service, err)
+}
 
-	return &r, err
+// failed reports whether the given service couldn't be queried for the snap
+func (res *snapResult) failed(service string) bool {
+	for _, e := range res.queryErrors {
+		if e.service == service {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/canonical/edgex-snap-info/snapinfo"
+)
+
+// fixture is a canned response
+type fixture struct {
+	status int
+	body   string
+}
+
+// fixtureServer serves canned responses by path, replacing {{URL}} in the bodies by its own URL
+type fixtureServer struct {
+	*httptest.Server
+}
+
+func newFixtureServer(t *testing.T, fixtures map[string]fixture) *fixtureServer {
+	t.Helper()
+	s := &fixtureServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, found := fixtures[r.URL.Path]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if f.status != 0 {
+			w.WriteHeader(f.status)
+		}
+		w.Write([]byte(strings.ReplaceAll(f.body, "{{URL}}", "http://"+r.Host)))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fixtureServer) client() *snapinfo.Client {
+	c := snapinfo.NewClient(s.Server.Client())
+	c.SnapStoreURL, c.LaunchpadURL, c.GithubURL = s.URL, s.URL, s.URL
+	return c
+}
+
+const snapInfoFixture = `{
+	"channel-map": [
+		{"channel": {"architecture": "amd64", "track": "latest", "risk": "stable", "released-at": "2024-01-02T03:04:05Z"},
+			"revision": 101, "version": "3.1.0", "base": "core22", "confinement": "strict", "grade": "stable", "download": {"size": 1024}},
+		{"channel": {"architecture": "arm64", "track": "latest", "risk": "stable", "released-at": "2024-01-02T03:04:05Z"},
+			"revision": 102, "version": "3.1.0", "base": "core22", "confinement": "strict", "grade": "stable"},
+		{"channel": {"architecture": "amd64", "track": "latest", "risk": "edge", "released-at": "2024-02-03T04:05:06Z"},
+			"revision": 103, "version": "3.2.0-dev.1", "base": "core22", "confinement": "devmode", "grade": "devel"}
+	],
+	"default-track": "latest",
+	"snap": {"publisher": {"username": "canonical", "display-name": "Canonical", "validation": "verified"}}
+}`
+
+const buildsFixture = `{
+	"entries": [
+		{"title": "amd64 build", "store_upload_revision": 101, "buildstate": "Successfully built", "arch_tag": "amd64",
+			"date_started": "2024-01-01T10:00:00Z", "datebuilt": "2024-01-01T10:20:00Z"},
+		{"title": "arm64 build", "store_upload_revision": 102, "buildstate": "Failed to build", "arch_tag": "arm64"}
+	],
+	"next_collection_link": "{{URL}}/builds-page-2"
+}`
+
+const buildsPage2Fixture = `{
+	"entries": [
+		{"title": "edge build", "store_upload_revision": 103, "buildstate": "Currently building", "arch_tag": "amd64"}
+	]
+}`
+
+const runsFixture = `{
+	"workflow_runs": [
+		{"name": "Snap Testing", "status": "completed", "conclusion": "failure", "display_title": "Fix the config",
+			"html_url": "https://github.com/edgexfoundry/edgex-go/actions/runs/1", "head_sha": "0123456789abcdef"},
+		{"name": "Snap Testing", "status": "completed", "conclusion": "success"},
+		{"name": "Lint", "status": "completed", "conclusion": "failure"},
+		{"name": "Snap Testing", "status": "in_progress"}
+	]
+}`
+
+const (
+	snapInfoPath = "/v2/snaps/info/edgexfoundry"
+	buildsPath   = "/devel/~canonical-edgex/+snap/edgexfoundry/builds"
+	runsPath     = "/repos/edgexfoundry/edgex-go/actions/runs"
+)
+
+// healthyFixtures are the responses of all services for the edgexfoundry snap
+func healthyFixtures() map[string]fixture {
+	return map[string]fixture{
+		snapInfoPath:     {body: snapInfoFixture},
+		buildsPath:       {body: buildsFixture},
+		"/builds-page-2": {body: buildsPage2Fixture},
+		runsPath:         {body: runsFixture},
+	}
+}
+
+func TestQuerySnapStore(t *testing.T) {
+	srv := newFixtureServer(t, healthyFixtures())
+	info, err := srv.client().QuerySnapStore(context.Background(), "edgexfoundry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.ChannelMap) != 3 {
+		t.Fatalf("got %d channels, want 3", len(info.ChannelMap))
+	}
+	cm := info.ChannelMap[0]
+	if cm.Channel.Architecture != "amd64" || cm.Channel.Track != "latest" || cm.Channel.Risk != "stable" {
+		t.Errorf("unexpected channel %+v", cm.Channel)
+	}
+	if cm.Revision != 101 || cm.Version != "3.1.0" || cm.Base != "core22" || cm.Confinement != "strict" || cm.Grade != "stable" || cm.Download.Size != 1024 {
+		t.Errorf("unexpected revision %+v", cm)
+	}
+	if cm.Channel.ReleasedAt.IsZero() {
+		t.Error("no release date")
+	}
+	if info.DefaultTrackOrLatest() != "latest" {
+		t.Errorf("default track is %q", info.DefaultTrackOrLatest())
+	}
+	if got := info.Snap.Publisher.String(); got != "Canonical (canonical)" {
+		t.Errorf("publisher is %q", got)
+	}
+}
+
+func TestQueryLaunchpad(t *testing.T) {
+	tests := []struct {
+		name   string
+		wanted map[uint]bool
+		// builds is the number of builds fetched, over one or two pages
+		builds int
+	}{
+		{"all built on the first page", map[uint]bool{101: true}, 2},
+		{"following the pagination", map[uint]bool{101: true, 102: true}, 3},
+		{"no wanted revisions", nil, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFixtureServer(t, healthyFixtures())
+			builds, err := srv.client().QueryLaunchpad(context.Background(), "canonical-edgex", "edgexfoundry", tt.wanted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(builds.Entries) != tt.builds {
+				t.Fatalf("got %d builds, want %d", len(builds.Entries), tt.builds)
+			}
+			b := builds.Entries[0]
+			if b.Title != "amd64 build" || b.StoreUploadRevision == nil || *b.StoreUploadRevision != 101 || b.BuildState != "Successfully built" || b.ArchTag != "amd64" {
+				t.Errorf("unexpected build %+v", b)
+			}
+			if d, ok := b.Duration(); !ok || d.Minutes() != 20 {
+				t.Errorf("build took %s", d)
+			}
+		})
+	}
+}
+
+func TestQueryGithub(t *testing.T) {
+	srv := newFixtureServer(t, healthyFixtures())
+	runs, err := srv.client().QueryGithub(context.Background(), "edgexfoundry/edgex-go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs.WorkflowRuns) != 4 {
+		t.Fatalf("got %d runs, want 4", len(runs.WorkflowRuns))
+	}
+	run := runs.WorkflowRuns[0]
+	if run.Name != "Snap Testing" || run.Conclusion != "failure" || run.DisplayTitle != "Fix the config" || run.ShortSHA() != "0123456" {
+		t.Errorf("unexpected run %+v", run)
+	}
+	if !run.Completed() || runs.WorkflowRuns[3].Completed() {
+		t.Error("unexpected completion of the runs")
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	queries := map[string]struct {
+		path  string
+		query func(c *snapinfo.Client) error
+	}{
+		"snap store": {snapInfoPath, func(c *snapinfo.Client) error {
+			_, err := c.QuerySnapStore(context.Background(), "edgexfoundry")
+			return err
+		}},
+		"launchpad": {buildsPath, func(c *snapinfo.Client) error {
+			_, err := c.QueryLaunchpad(context.Background(), "canonical-edgex", "edgexfoundry", nil)
+			return err
+		}},
+		"github": {runsPath, func(c *snapinfo.Client) error {
+			_, err := c.QueryGithub(context.Background(), "edgexfoundry/edgex-go")
+			return err
+		}},
+	}
+	responses := map[string]struct {
+		fixture fixture
+		wantErr string
+	}{
+		"non-2xx":   {fixture{status: http.StatusInternalServerError, body: "oops"}, "500 Internal Server Error: oops"},
+		"not found": {fixture{status: http.StatusNotFound, body: `{"error": "not found"}`}, "404 Not Found"},
+		"malformed": {fixture{body: `{"entries": [`}, "unexpected EOF"},
+	}
+	for service, q := range queries {
+		for name, r := range responses {
+			t.Run(service+"/"+name, func(t *testing.T) {
+				srv := newFixtureServer(t, map[string]fixture{q.path: r.fixture})
+				if err := q.query(srv.client()); err == nil || !strings.Contains(err.Error(), r.wantErr) {
+					t.Errorf("got error %v, want %q", err, r.wantErr)
+				}
+			})
+		}
+	}
+}
+
+// collect collects the edgexfoundry snap from the server, as the command does
+func collect(t *testing.T, srv *fixtureServer) snapResult {
+	t.Helper()
+	c := &collector{client: srv.client(), concurrency: 1, workflow: "Snap Testing", retrying: &retryTransport{}}
+	conf := &config{Snaps: map[string]SnapConfig{
+		"edgexfoundry": {GithubRepo: "edgexfoundry/edgex-go", LaunchpadOwner: "canonical-edgex"},
+	}}
+	results := c.collectAll(context.Background(), conf, []string{"edgexfoundry"})
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	return results[0]
+}
+
+func TestCollectStatus(t *testing.T) {
+	res := collect(t, newFixtureServer(t, healthyFixtures()))
+	if res.Error != "" {
+		t.Fatalf("unexpected error: %s", res.Error)
+	}
+
+	// the edge release is filtered out by default
+	want := map[string]string{"amd64": buildSucceeded, "arm64": buildFailed}
+	if len(res.Rows) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(res.Rows), len(want))
+	}
+	for _, r := range res.Rows {
+		if r.Channel != "latest/stable" || r.Build != want[r.Arch] {
+			t.Errorf("%s %s has build %q, want %q", r.Channel, r.Arch, r.Build, want[r.Arch])
+		}
+		if r.LastBuild != "20m0s" {
+			t.Errorf("last build took %s", r.LastBuild)
+		}
+	}
+	if !res.missingBuilds() {
+		t.Error("the failed arm64 build isn't missing")
+	}
+
+	// the Lint run isn't counted
+	if res.TestsTotal != 3 || res.TestsFailed != 1 || res.TestsRunning != 1 {
+		t.Errorf("got %d/%d failed tests, %d running", res.TestsFailed, res.TestsTotal, res.TestsRunning)
+	}
+	if res.Test != "🔴 failed 1/3, 1 running" {
+		t.Errorf("test summary is %q", res.Test)
+	}
+	if len(res.FailedRuns) != 1 || res.FailedRuns[0].DisplayTitle != "Fix the config" {
+		t.Errorf("unexpected failed runs %+v", res.FailedRuns)
+	}
+	if res.testsPassed() || res.healthy() {
+		t.Error("snap with failed tests and builds is healthy")
+	}
+}
+
+func TestCollectEmptyChannelMap(t *testing.T) {
+	fixtures := healthyFixtures()
+	fixtures[snapInfoPath] = fixture{body: `{"channel-map": []}`}
+	res := collect(t, newFixtureServer(t, fixtures))
+	if len(res.Rows) != 0 || res.Note != "(no releases)" {
+		t.Errorf("got %d rows and note %q", len(res.Rows), res.Note)
+	}
+	if res.missingBuilds() {
+		t.Error("a snap without releases has missing builds")
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		fixture fixture
+		wantErr string
+		// rows is the number of rows still collected
+		rows int
+	}{
+		{"store non-2xx", snapInfoPath, fixture{status: http.StatusServiceUnavailable}, "snapstore: unexpected response status", 0},
+		{"store malformed", snapInfoPath, fixture{body: `{"channel-map": {}}`}, "snapstore: json: cannot unmarshal", 0},
+		{"launchpad non-2xx", buildsPath, fixture{status: http.StatusInternalServerError}, "launchpad: unexpected response status", 2},
+		{"github malformed", runsPath, fixture{body: `not json`}, "github: invalid character", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixtures := healthyFixtures()
+			fixtures[tt.path] = tt.fixture
+			res := collect(t, newFixtureServer(t, fixtures))
+			if !strings.Contains(res.Error, tt.wantErr) {
+				t.Errorf("got error %q, want %q", res.Error, tt.wantErr)
+			}
+			if len(res.Rows) != tt.rows {
+				t.Errorf("got %d rows, want %d", len(res.Rows), tt.rows)
+			}
+			if res.healthy() {
+				t.Error("snap with errors is healthy")
+			}
+		})
+	}
+}
+
+func TestCollectLaunchpadErrorLeavesBuildsUnknown(t *testing.T) {
+	fixtures := healthyFixtures()
+	fixtures[buildsPath] = fixture{body: `{"entries": [`}
+	res := collect(t, newFixtureServer(t, fixtures))
+	for _, r := range res.Rows {
+		if r.Build != "" {
+			t.Errorf("%s %s has build %q without Launchpad", r.Channel, r.Arch, r.Build)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	srv := newFixtureServer(t, map[string]fixture{
+		"/config/main.json":   {body: `{"include": ["base.yaml"], "snaps": {"edgex-ui": {"githubRepo": "edgexfoundry/edgex-ui-go", "launchpadOwner": "someone"}}}`},
+		"/config/base.yaml":   {body: "snaps:\n  edgex-cli:\n    githubRepo: edgexfoundry/edgex-cli\n  edgex-ui:\n    githubRepo: edgexfoundry/old\n"},
+		"/config/error.json":  {status: http.StatusInternalServerError, body: "oops"},
+		"/config/bad.json":    {body: `{"snaps": {"edgex-ui": {"githubRepo": "edgex-ui-go"}}}`},
+		"/config/broken.json": {body: `{"snaps": `},
+	})
+	ctx := context.Background()
+
+	conf, err := loadConfig(ctx, srv.Server.Client(), srv.URL+"/config/main.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.Snaps) != 2 {
+		t.Fatalf("got %d snaps, want 2", len(conf.Snaps))
+	}
+	if ui := conf.Snaps["edgex-ui"]; ui.GithubRepo != "edgexfoundry/edgex-ui-go" || ui.LaunchpadOwner != "someone" {
+		t.Errorf("the including config doesn't override the include: %+v", ui)
+	}
+	if cli := conf.Snaps["edgex-cli"]; cli.GithubRepo != "edgexfoundry/edgex-cli" || cli.LaunchpadOwner != defaultLaunchpadOwner {
+		t.Errorf("unexpected included snap %+v", cli)
+	}
+
+	if _, err := loadConfig(ctx, srv.Server.Client(), srv.URL+"/config/missing.json"); !errors.Is(err, errConfigNotFound) {
+		t.Errorf("got error %v for a missing config", err)
+	}
+
+	for file, wantErr := range map[string]string{
+		"error.json":  "unexpected response status",
+		"bad.json":    "not in owner/name form",
+		"broken.json": "unexpected EOF",
+	} {
+		if _, err := loadConfig(ctx, srv.Server.Client(), srv.URL+"/config/"+file); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("%s: got error %v, want %q", file, err, wantErr)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// renderBuildMatrix prints a grid of the revisions of the architecture released to each risk,
+// with the status of their builds, one line per snap and track
+func renderBuildMatrix(w io.Writer, results []snapResult, arch string) error {
+	type key struct{ track, risk string }
+	// the risks released on the architecture by any snap
+	var risks []string
+	for _, res := range results {
+		for _, r := range res.Rows {
+			if risk := strings.ToLower(r.risk); strings.EqualFold(r.Arch, arch) && !oneOf(risk, risks) {
+				risks = append(risks, risk)
+			}
+		}
+	}
+	sort.Slice(risks, func(i, j int) bool {
+		if ri, rj := riskRank(risks[i]), riskRank(risks[j]); ri != rj {
+			return ri < rj
+		}
+		return risks[i] < risks[j]
+	})
+
+	notes := false
+	for _, res := range results {
+		notes = notes || res.Error != "" || res.Note != ""
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(w)
+	t.SetStyle(table.StyleLight)
+	header := table.Row{"Name", "Track"}
+	for _, risk := range risks {
+		header = append(header, risk)
+	}
+	if notes {
+		header = append(header, "Note")
+	}
+	t.AppendHeader(header)
+
+	for _, res := range results {
+		name := res.displayName()
+		note := res.Note
+		if res.Error != "" {
+			note = res.Error
+		}
+
+		cells := make(map[key]row)
+		var tracks []string
+		for _, r := range res.Rows {
+			if !strings.EqualFold(r.Arch, arch) {
+				continue
+			}
+			if !oneOf(r.track, tracks) {
+				tracks = append(tracks, r.track)
+			}
+			cells[key{r.track, strings.ToLower(r.risk)}] = r
+		}
+		sort.Strings(tracks)
+		if len(tracks) == 0 {
+			// keep the snap in the grid, so that an arch without any release stands out
+			line := table.Row{name, "-"}
+			for range risks {
+				line = append(line, "")
+			}
+			if notes {
+				line = append(line, note)
+			}
+			t.AppendRow(line)
+			continue
+		}
+
+		for _, track := range tracks {
+			line := table.Row{name, track}
+			for _, risk := range risks {
+				r, found := cells[key{track, risk}]
+				if !found {
+					line = append(line, "")
+					continue
+				}
+				line = append(line, strings.TrimSpace(fmt.Sprintf("%d %s", r.Revision, r.Build)))
+			}
+			if notes {
+				line = append(line, note)
+			}
+			t.AppendRow(line)
+		}
+	}
+	t.SetCaption("Builds on %s: %s built, %s building, %s pending, %s failed, %s missing", arch, buildSucceeded, buildRunning, buildPending, buildFailed, buildMissing)
+	t.Render()
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// renderPrometheus renders the results as metrics in the Prometheus text format,
+// e.g. for the textfile collector of node_exporter
+func renderPrometheus(w io.Writer, results []snapResult, generatedAt time.Time) error {
+	bw := bufio.NewWriter(w)
+
+	metric := func(name, help string, values func(func(labels string, value any))) {
+		fmt.Fprintf(bw, "# HELP %s %s\n", name, help)
+		fmt.Fprintf(bw, "# TYPE %s gauge\n", name)
+		values(func(labels string, value any) {
+			fmt.Fprintf(bw, "%s{%s} %v\n", name, labels, value)
+		})
+	}
+
+	metric("edgex_snap_generated_timestamp_seconds", "When the report was generated, in seconds since the epoch.", func(emit func(string, any)) {
+		emit("", generatedAt.Unix())
+	})
+	metric("edgex_snap_revision", "Revision released to the channel.", func(emit func(string, any)) {
+		for _, res := range results {
+			for _, r := range res.Rows {
+				emit(channelLabels(r), r.Revision)
+			}
+		}
+	})
+	metric("edgex_snap_build_success", "Whether the released revision has a successful build.", func(emit func(string, any)) {
+		for _, res := range results {
+			for _, r := range res.Rows {
+				emit(channelLabels(r), boolValue(r.built()))
+			}
+		}
+	})
+	metric("edgex_snap_tests_failed", "Number of failed test runs.", func(emit func(string, any)) {
+		for _, res := range results {
+			emit(labels("snap", res.Name), res.TestsFailed)
+		}
+	})
+	metric("edgex_snap_tests_total", "Number of test runs.", func(emit func(string, any)) {
+		for _, res := range results {
+			emit(labels("snap", res.Name), res.TestsTotal)
+		}
+	})
+	metric("edgex_snap_tests_running", "Number of test runs that are queued or in progress.", func(emit func(string, any)) {
+		for _, res := range results {
+			emit(labels("snap", res.Name), res.TestsRunning)
+		}
+	})
+	metric("edgex_snap_up", "Whether all services were queried successfully.", func(emit func(string, any)) {
+		for _, res := range results {
+			emit(labels("snap", res.Name), boolValue(res.Error == ""))
+		}
+	})
+
+	return bw.Flush()
+}
+
+func channelLabels(r row) string {
+	return labels("snap", r.Name, "track", r.track, "risk", r.risk, "arch", r.Arch)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels formats the given name and value pairs as metric labels
+func labels(pairs ...string) string {
+	var s []string
+	for i := 0; i+1 < len(pairs); i += 2 {
+		s = append(s, fmt.Sprintf(`%s="%s"`, pairs[i], labelEscaper.Replace(pairs[i+1])))
+	}
+	return strings.Join(s, ",")
+}
+
+func boolValue(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
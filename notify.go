@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/canonical/edgex-snap-info/snapinfo"
+)
+
+// failures returns the snaps with failed test runs or missing builds
+func failures(results []snapResult) []snapResult {
+	var failed []snapResult
+	for _, res := range results {
+		if res.TestsFailed > 0 || res.missingBuilds() {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// notifier notifies about the failures after each collection.
+// With a state file, it only notifies when the health of a snap changes.
+type notifier struct {
+	client       *http.Client
+	slackWebhook string
+	email        *emailer
+	stateFile    string
+}
+
+func (n *notifier) enabled() bool {
+	return n.slackWebhook != "" || n.email.enabled()
+}
+
+// notify notifies about the failures and recoveries, emailing the report rendered with the given options
+func (n *notifier) notify(ctx context.Context, results []snapResult, opts renderOptions) error {
+	failed, recovered := failures(results), []snapResult(nil)
+	var next state
+	if n.stateFile != "" {
+		prev, err := loadState(n.stateFile)
+		if err != nil {
+			return fmt.Errorf("loading state file: %w", err)
+		}
+		failed, recovered, next = prev.transitions(results)
+	}
+
+	changed := len(failed) > 0 || len(recovered) > 0
+	if n.slackWebhook != "" && changed {
+		if err := notifySlack(ctx, n.client, n.slackWebhook, failed, recovered); err != nil {
+			return fmt.Errorf("Slack: %w", err)
+		}
+	}
+	if n.email.enabled() && (changed || n.email.always) {
+		if err := n.email.send(results, failed, recovered, opts); err != nil {
+			return fmt.Errorf("email: %w", err)
+		}
+	}
+
+	// only after a successful notification, so that a failed one is retried on the next run
+	if n.stateFile != "" {
+		if err := saveState(n.stateFile, next); err != nil {
+			return fmt.Errorf("saving state file: %w", err)
+		}
+	}
+	return nil
+}
+
+// slackMessage formats the failures and recoveries as a Slack message, linking the failed runs
+func slackMessage(failed, recovered []snapResult) string {
+	var b strings.Builder
+	if len(failed) > 0 {
+		fmt.Fprintf(&b, "EdgeX snaps with failures: %d\n", len(failed))
+	}
+	for _, res := range failed {
+		fmt.Fprintf(&b, "*%s*", res.Name)
+		if res.TestsFailed > 0 {
+			fmt.Fprintf(&b, " %s", res.Test)
+		}
+		if res.missingBuilds() {
+			b.WriteString(" ❌ missing builds")
+		}
+		b.WriteString("\n")
+		for _, run := range res.FailedRuns {
+			fmt.Fprintf(&b, "    • <%s|%s>\n", run.HTMLURL, run.DisplayTitle)
+		}
+	}
+	if len(recovered) > 0 {
+		fmt.Fprintf(&b, "EdgeX snaps recovered: %d\n", len(recovered))
+	}
+	for _, res := range recovered {
+		fmt.Fprintf(&b, "*%s* 🟢\n", res.Name)
+	}
+	return b.String()
+}
+
+// notifySlack posts a summary of the failures and recoveries to a Slack incoming webhook
+func notifySlack(ctx context.Context, client *http.Client, webhook string, failed, recovered []snapResult) error {
+	body, err := json.Marshal(map[string]string{"text": slackMessage(failed, recovered)})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		// without the URL, whose path is the secret of the webhook
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("%s webhook: %w", urlErr.Op, urlErr.Err)
+		}
+		return err
+	}
+	defer snapinfo.CloseBody(res)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status: %s", res.Status)
+	}
+	infof("📣 Notified Slack about %d snaps", len(failed)+len(recovered))
+	return nil
+}
@@ -0,0 +1,763 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/canonical/edgex-snap-info/snapinfo"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	"golang.org/x/term"
+)
+
+const (
+	formatTable      = "table"
+	formatJSON       = "json"
+	formatNDJSON     = "ndjson"
+	formatCSV        = "csv"
+	formatMarkdown   = "markdown"
+	formatHTML       = "html"
+	formatPrometheus = "prometheus"
+	formatSummary    = "summary"
+)
+
+var formats = []string{formatTable, formatJSON, formatNDJSON, formatCSV, formatMarkdown, formatHTML, formatPrometheus, formatSummary}
+
+// oneOf reports whether the value is one of the accepted values
+func oneOf(value string, accepted []string) bool {
+	for _, a := range accepted {
+		if a == value {
+			return true
+		}
+	}
+	return false
+}
+
+// snapResult holds the collected info for a single snap
+type snapResult struct {
+	Name string
+	// DisplayName is shown instead of the name when set
+	DisplayName string
+	// DefaultTrack is the default track of the snap in the store, or "" when unknown
+	DefaultTrack string
+	Rows         []row
+	// Test is the summary of the snap's GitHub test runs
+	Test                    string
+	TestsFailed, TestsTotal uint
+	// TestsRunning is the number of test runs that are queued or in progress
+	TestsRunning uint
+	// NoTests is set when the snap has no GitHub repo, so its tests aren't checked
+	NoTests bool
+	// FailedRuns are the GitHub runs that failed
+	FailedRuns []snapinfo.WorkflowRun
+	// Note is an informational message about the snap
+	Note string
+	// Error lists the errors encountered while querying the services
+	Error string
+	// queryErrors are the same errors, by service, e.g. for summarizing them after the run
+	queryErrors []queryError
+	// channels are all the released revisions, before filtering the rows
+	channels []snapinfo.ChannelResult
+}
+
+// Kinds of errors querying a service, grouped in the summary of the errors
+const (
+	errorTimeout     = "timed out"
+	errorRateLimited = "rate-limited"
+	errorFailed      = "failed"
+)
+
+// queryError is an error querying a service for a snap
+type queryError struct {
+	service string
+	kind    string
+	err     error
+}
+
+func (res *snapResult) addError(format string, a ...any) {
+	if res.Error != "" {
+		res.Error += "; "
+	}
+	res.Error += fmt.Sprintf(format, a...)
+}
+
+func (res *snapResult) addNote(format string, a ...any) {
+	if res.Note != "" {
+		res.Note += "; "
+	}
+	res.Note += fmt.Sprintf(format, a...)
+}
+
+// row is a single channel-map entry of a snap
+type row struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name,omitempty"`
+	Channel     string `json:"channel"`
+	Version     string `json:"version"`
+	// Mismatch is set when other architectures of the channel carry a different version
+	Mismatch    bool   `json:"version_mismatch,omitempty"`
+	Arch        string `json:"arch"`
+	Confinement string `json:"confinement,omitempty"`
+	Base        string `json:"base,omitempty"`
+	Grade       string `json:"grade,omitempty"`
+	Publisher   string `json:"publisher,omitempty"`
+	// Size is the download size in bytes
+	Size     uint64 `json:"size,omitempty"`
+	Revision uint   `json:"revision"`
+	// Date is when the revision was released, or nil when unknown
+	Date  *time.Time `json:"date,omitempty"`
+	Build string     `json:"build"`
+	// LastBuild is the duration of the snap's most recent build
+	LastBuild string `json:"last_build,omitempty"`
+	Test      string `json:"test"`
+	Note      string `json:"note,omitempty"`
+	Error     string `json:"error,omitempty"`
+
+	track, risk string
+}
+
+// renderOptions controls how the results are rendered
+type renderOptions struct {
+	// color enables ANSI colors in the table format
+	color bool
+	// absoluteDates adds the release dates next to their age
+	absoluteDates bool
+	// showBase and showConfinement add the base, and the confinement and grade of the revisions
+	showBase, showConfinement bool
+	// showSize adds the download size of the revisions
+	showSize bool
+	// showPublisher adds the publisher of the snaps
+	showPublisher bool
+	// onlyProblems hides the healthy snaps, while still counting them in the summary
+	onlyProblems bool
+	// generatedAt is when the report was generated, in UTC
+	generatedAt time.Time
+	// maxWidth caps the width of the table, wrapping the cells, or 0 for no limit
+	maxWidth int
+	// incomplete tells why some snaps are missing from the report, e.g. when the deadline was exceeded
+	incomplete string
+}
+
+// generated returns a human-readable line telling when the report was generated, and whether it is incomplete
+func (opts renderOptions) generated() string {
+	line := "Generated at " + opts.generatedAt.Format(time.RFC1123)
+	if opts.incomplete != "" {
+		line += "; " + opts.incomplete
+	}
+	return line
+}
+
+func render(w io.Writer, format string, results []snapResult, opts renderOptions) error {
+	shown := results
+	if opts.onlyProblems {
+		shown = problems(results)
+	}
+	switch format {
+	case formatJSON:
+		return renderJSON(w, shown, opts)
+	case formatNDJSON:
+		return renderNDJSON(w, shown, opts.generatedAt)
+	case formatCSV:
+		return renderCSV(w, shown, opts.generatedAt)
+	case formatMarkdown:
+		renderMarkdown(w, results, opts)
+		return nil
+	case formatHTML:
+		return renderHTML(w, results, opts)
+	case formatPrometheus:
+		return renderPrometheus(w, results, opts.generatedAt)
+	case formatSummary:
+		return renderSummary(w, shown, results, opts)
+	default:
+		renderTable(w, results, opts)
+		return nil
+	}
+}
+
+// column is a column of the table
+type column struct {
+	header string
+	value  func(r row) any
+	// merge enables merging identical cells vertically
+	merge bool
+	// widthMax is the width beyond which the cells are wrapped, or 0 for no limit
+	widthMax int
+}
+
+// columns returns the columns of the table, depending on the options
+func columns(opts renderOptions) []column {
+	cols := []column{
+		{header: "Name", value: func(r row) any { return r.displayName() }, merge: true, widthMax: 30},
+		{header: "Channel", value: func(r row) any { return r.Channel }, merge: true, widthMax: 30},
+		{header: "Version", value: func(r row) any { return r.version() }, merge: true, widthMax: 30},
+		{header: "Arch", value: func(r row) any { return r.Arch }, widthMax: 40},
+		{header: "Rev", value: func(r row) any { return formatRevision(r.Revision) }},
+	}
+	if opts.showConfinement {
+		cols = append(cols,
+			column{header: "Confinement", value: func(r row) any { return r.Confinement }},
+			column{header: "Grade", value: func(r row) any { return r.Grade }},
+		)
+	}
+	if opts.showBase {
+		cols = append(cols, column{header: "Base", value: func(r row) any { return r.Base }})
+	}
+	if opts.showPublisher {
+		cols = append(cols, column{header: "Publisher", value: func(r row) any { return r.Publisher }, merge: true, widthMax: 30})
+	}
+	if opts.showSize {
+		cols = append(cols, column{header: "Size", value: func(r row) any { return formatSize(r.Size) }})
+	}
+	if opts.absoluteDates {
+		cols = append(cols, column{header: "Date", value: func(r row) any { return formatDate(r.Date, time.Stamp) }})
+	}
+	cols = append(cols,
+		column{header: "Age", value: func(r row) any { return r.age() }},
+		column{header: "Build", value: func(r row) any { return r.Build }},
+		column{header: "Last Build", value: func(r row) any { return r.LastBuild }, merge: true},
+	)
+	return cols
+}
+
+// newTable fills a table with the results.
+// With merge, the cells of the per-snap summary rows are merged across columns for terminal rendering.
+func newTable(w io.Writer, results []snapResult, merge bool, opts renderOptions) table.Writer {
+	t := table.NewWriter()
+	t.SetOutputMirror(w)
+
+	cols := columns(opts)
+	var header table.Row
+	var configs []table.ColumnConfig
+	for i, col := range cols {
+		header = append(header, col.header)
+		if col.merge && !merge {
+			configs = append(configs, table.ColumnConfig{Number: i + 1, AutoMerge: true})
+		}
+	}
+	if merge {
+		// Only the terminal table is wrapped, as line breaks don't fit into the cells of Markdown and HTML tables.
+		// Instead of merging the wrapped cells vertically, which keeps the height of each of them,
+		// the repeated cells are left blank.
+		for i, width := range fitWidths(cols, results, opts.maxWidth) {
+			configs = append(configs, table.ColumnConfig{Number: i + 1, WidthMax: width, WidthMaxEnforcer: text.WrapSoft})
+		}
+	}
+	t.AppendHeader(header)
+	t.SetColumnConfigs(configs)
+
+	var prev table.Row
+	appendRow := func(cells table.Row, config ...table.RowConfig) {
+		shown := make(table.Row, len(cells))
+		for i, cell := range cells {
+			if merge && i < len(prev) && cols[i].merge && cell == prev[i] && cell != "" {
+				cell = ""
+			}
+			shown[i] = cell
+		}
+		prev = cells
+		t.AppendRow(shown, config...)
+	}
+	for _, res := range results {
+		if opts.onlyProblems && res.healthy() {
+			continue
+		}
+		prev = nil
+		groups := groupByTrack(res.Rows)
+		for _, group := range groups {
+			if len(groups) > 1 {
+				// label the track of multi-track snaps
+				cells := table.Row{res.displayName(), "track " + group[0].track}
+				if merge {
+					// blank cells, merged across the remaining columns
+					for range cols[2:] {
+						cells = append(cells, "")
+					}
+					appendRow(cells, table.RowConfig{AutoMerge: true})
+				} else {
+					t.AppendRow(cells)
+				}
+			}
+			for _, r := range group {
+				var cells table.Row
+				for _, col := range cols {
+					cells = append(cells, col.value(r))
+				}
+				appendRow(cells, table.RowConfig{AutoMerge: !merge})
+			}
+		}
+		msg := res.messages()
+		first := res.Test
+		if len(res.Rows) == 0 {
+			// there is no row above naming the snap
+			first = strings.TrimSpace(res.displayName() + " " + res.Test)
+		}
+		if merge {
+			cells := table.Row{first}
+			for range cols[1:] {
+				cells = append(cells, msg)
+			}
+			t.AppendRow(cells, table.RowConfig{AutoMerge: true})
+		} else {
+			t.AppendRow(table.Row{res.displayName(), res.Test, msg})
+		}
+		t.AppendSeparator()
+	}
+
+	var footer table.Row
+	for _, count := range healthSummary(results) {
+		footer = append(footer, count)
+	}
+	t.AppendFooter(footer)
+	t.Style().Format.Footer = text.FormatDefault
+
+	return t
+}
+
+// minColumnWidth is the narrowest a column gets when fitting the table into the maximum width
+const minColumnWidth = 8
+
+// fitWidths returns the widths of the columns of the table, wrapping the cells beyond the limit of
+// each column, and shrinking the widest columns until the table fits into the maximum width, if any
+func fitWidths(cols []column, results []snapResult, maxWidth int) []int {
+	widths := make([]int, len(cols))
+	fit := func(i int, cell string) {
+		for _, line := range strings.Split(cell, "\n") {
+			widths[i] = max(widths[i], text.RuneWidthWithoutEscSequences(line))
+		}
+	}
+	for i, col := range cols {
+		fit(i, col.header)
+	}
+	for _, res := range results {
+		if len(res.Rows) == 0 {
+			fit(0, strings.TrimSpace(res.displayName()+" "+res.Test))
+		} else {
+			fit(0, res.displayName())
+			fit(0, res.Test)
+		}
+		for _, r := range res.Rows {
+			fit(1, "track "+r.track)
+			for i, col := range cols {
+				fit(i, fmt.Sprint(col.value(r)))
+			}
+		}
+	}
+	for i, count := range healthSummary(results) {
+		if i < len(widths) {
+			fit(i, count)
+		}
+	}
+	for i, col := range cols {
+		if col.widthMax > 0 {
+			widths[i] = min(widths[i], col.widthMax)
+		}
+	}
+	if maxWidth <= 0 {
+		return widths
+	}
+
+	// the borders and padding take 3 characters per column, and one for the last border
+	available := maxWidth - 3*len(cols) - 1
+	for {
+		total, widest := 0, 0
+		for i, w := range widths {
+			total += w
+			if w > widths[widest] {
+				widest = i
+			}
+		}
+		if total <= available || widths[widest] <= minColumnWidth {
+			return widths
+		}
+		widths[widest]--
+	}
+}
+
+// groupByTrack groups the rows by track, in the order in which the tracks first appear
+func groupByTrack(rows []row) [][]row {
+	var groups [][]row
+	index := make(map[string]int)
+	for _, r := range rows {
+		i, found := index[r.track]
+		if !found {
+			i = len(groups)
+			index[r.track] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], r)
+	}
+	return groups
+}
+
+// formatRevision formats a revision, leaving unknown or non-uniform revisions blank
+func formatRevision(rev uint) string {
+	if rev == 0 {
+		return ""
+	}
+	return strconv.FormatUint(uint64(rev), 10)
+}
+
+// formatSize formats a size in bytes as megabytes, leaving unknown sizes blank
+func formatSize(size uint64) string {
+	if size == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.1f MB", float64(size)/1e6)
+}
+
+// formatAge formats a duration in a compact, human-readable form such as 3d, 5w or 2mo
+func formatAge(d time.Duration) string {
+	const day = 24 * time.Hour
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < day:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	case d < 14*day:
+		return fmt.Sprintf("%dd", int(d/day))
+	case d < 60*day:
+		return fmt.Sprintf("%dw", int(d/(7*day)))
+	case d < 365*day:
+		return fmt.Sprintf("%dmo", int(d/(30*day)))
+	default:
+		return fmt.Sprintf("%dy", int(d/(365*day)))
+	}
+}
+
+func renderTable(w io.Writer, results []snapResult, opts renderOptions) {
+	t := newTable(w, results, true, opts)
+	if opts.color {
+		t.SetStyle(table.StyleColoredBright)
+	} else {
+		t.SetStyle(table.StyleLight)
+	}
+	t.Style().Format.Footer = text.FormatDefault
+	t.SetCaption(opts.generated())
+	t.Render()
+}
+
+// renderMarkdown renders the table without colors, for pasting into GitHub
+func renderMarkdown(w io.Writer, results []snapResult, opts renderOptions) {
+	t := newTable(w, results, false, opts)
+	t.SetCaption(opts.generated())
+	t.RenderMarkdown()
+}
+
+const htmlPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>EdgeX Snap Info</title>
+<style>
+  body { font-family: sans-serif; margin: 2em; }
+  table { border-collapse: collapse; }
+  th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; }
+  th { background: #eee; }
+  .red { color: #c00; }
+  .green { color: #080; }
+  .orange { color: #d80; }
+  .yellow { color: #cb0; }
+</style>
+</head>
+<body>
+<h1>EdgeX Snap Info</h1>
+<p>%s</p>
+%s
+</body>
+</html>
+`
+
+// statusClasses maps the status indicators to CSS classes
+var statusClasses = strings.NewReplacer(
+	"🔴", `<span class="red">🔴</span>`,
+	"❌", `<span class="red">❌</span>`,
+	"❗", `<span class="red">❗</span>`,
+	"🟢", `<span class="green">🟢</span>`,
+	"✅", `<span class="green">✅</span>`,
+	"🟠", `<span class="orange">🟠</span>`,
+	"⚠️", `<span class="orange">⚠️</span>`,
+	"🟡", `<span class="yellow">🟡</span>`,
+	"🔨", `<span class="yellow">🔨</span>`,
+	"⏳", `<span class="yellow">⏳</span>`,
+)
+
+// renderHTML renders a self-contained HTML document with the table
+func renderHTML(w io.Writer, results []snapResult, opts renderOptions) error {
+	t := newTable(nil, results, false, opts)
+	body := statusClasses.Replace(t.RenderHTML())
+	_, err := fmt.Fprintf(w, htmlPage, html.EscapeString(opts.generated()), body)
+	return err
+}
+
+// messages returns the note and error of the snap, prefixed with icons
+func (res *snapResult) messages() string {
+	var msgs []string
+	if res.Note != "" {
+		msgs = append(msgs, "ℹ️ "+res.Note)
+	}
+	if res.Error != "" {
+		msgs = append(msgs, "❗ "+res.Error)
+	}
+	return strings.Join(msgs, " ")
+}
+
+// displayName returns the display name of the snap, or else its name
+func (res *snapResult) displayName() string {
+	if res.DisplayName != "" {
+		return res.DisplayName
+	}
+	return res.Name
+}
+
+// displayName returns the display name of the snap, or else its name
+func (r row) displayName() string {
+	if r.DisplayName != "" {
+		return r.DisplayName
+	}
+	return r.Name
+}
+
+// version returns the version, marked when it differs across architectures
+func (r row) version() string {
+	if r.Mismatch {
+		return "⚠️ " + r.Version
+	}
+	return r.Version
+}
+
+// age returns how long ago the revision was released, or "" when unknown
+func (r row) age() string {
+	if r.Date == nil {
+		return ""
+	}
+	return formatAge(time.Since(*r.Date))
+}
+
+// flatten returns the rows of all results.
+// A snap without any rows but with a note or error is represented by a row carrying only those.
+func flatten(results []snapResult) []row {
+	rows := []row{}
+	for _, res := range results {
+		if len(res.Rows) == 0 && (res.Note != "" || res.Error != "") {
+			rows = append(rows, row{Name: res.Name, DisplayName: res.DisplayName, Test: res.Test, Note: res.Note, Error: res.Error})
+			continue
+		}
+		rows = append(rows, res.Rows...)
+	}
+	return rows
+}
+
+// renderJSON renders the rows in an object telling when they were generated, and whether the report is incomplete
+func renderJSON(w io.Writer, results []snapResult, opts renderOptions) error {
+	report := struct {
+		GeneratedAt time.Time `json:"generated_at"`
+		Incomplete  string    `json:"incomplete,omitempty"`
+		Rows        []row     `json:"rows"`
+	}{opts.generatedAt, opts.incomplete, flatten(results)}
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+// renderSummary renders one line per snap with the stable release of its default track,
+// whether all its releases have been built, and the status of its tests, followed by the health of all snaps
+func renderSummary(w io.Writer, shown, results []snapResult, opts renderOptions) error {
+	var lines [][]string
+	for _, res := range shown {
+		// the builds of the same stable release
+		build := ""
+		for _, r := range res.stableRows() {
+			if !r.built() {
+				build = buildMissing
+				break
+			}
+			build = buildSucceeded
+		}
+		line := []string{res.displayName(), res.stableRelease(), build, res.Test}
+		if res.Error != "" {
+			line = append(line, "error: "+res.Error)
+		}
+		lines = append(lines, line)
+	}
+
+	var widths []int
+	for _, line := range lines {
+		for i, cell := range line {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], text.RuneWidthWithoutEscSequences(cell))
+		}
+	}
+	bw := bufio.NewWriter(w)
+	for _, line := range lines {
+		var padded []string
+		for i, cell := range line {
+			padded = append(padded, text.Pad(cell, widths[i], ' '))
+		}
+		fmt.Fprintln(bw, strings.TrimRight(strings.Join(padded, "  "), " "))
+	}
+	fmt.Fprintln(bw, strings.Join(healthSummary(results), ", "))
+	fmt.Fprintln(bw, opts.generated())
+	return bw.Flush()
+}
+
+// stableRows returns the rows of the stable releases of the default track
+func (res *snapResult) stableRows() []row {
+	var rows []row
+	for _, r := range res.Rows {
+		if strings.EqualFold(r.track, res.DefaultTrack) && strings.EqualFold(r.risk, "stable") {
+			rows = append(rows, r)
+		}
+	}
+	return rows
+}
+
+// stableRelease describes the stable releases of the default track, e.g. "latest/stable 2.3.0 (100, 101)"
+func (res *snapResult) stableRelease() string {
+	if res.DefaultTrack == "" {
+		return ""
+	}
+	var versions, revisions []string
+	for _, r := range res.stableRows() {
+		if !oneOf(r.Version, versions) {
+			versions = append(versions, r.Version)
+		}
+		if rev := formatRevision(r.Revision); rev != "" && !oneOf(rev, revisions) {
+			revisions = append(revisions, rev)
+		}
+	}
+	channel := res.DefaultTrack + "/stable"
+	if len(versions) == 0 {
+		return channel + " not released"
+	}
+	release := channel + " " + strings.Join(versions, ", ")
+	if len(revisions) > 0 {
+		release += " (" + strings.Join(revisions, ", ") + ")"
+	}
+	return release
+}
+
+// renderNDJSON renders newline-delimited JSON, one object per row, for streaming into log pipelines.
+// Each object tells when the report was generated.
+func renderNDJSON(w io.Writer, results []snapResult, generatedAt time.Time) error {
+	e := json.NewEncoder(w)
+	for _, r := range flatten(results) {
+		line := struct {
+			GeneratedAt time.Time `json:"generated_at"`
+			row
+		}{generatedAt, r}
+		if err := e.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderCSV renders one line per row, with plain words instead of the status indicators for spreadsheets
+func renderCSV(w io.Writer, results []snapResult, generatedAt time.Time) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"generated_at", "name", "channel", "version", "version_mismatch", "arch", "revision", "date", "build", "last_build", "test", "tests_failed", "tests_total", "note", "error"})
+	for _, res := range results {
+		for _, r := range flatten([]snapResult{res}) {
+			cw.Write([]string{
+				generatedAt.Format(time.RFC3339),
+				r.Name,
+				r.Channel,
+				r.Version,
+				strconv.FormatBool(r.Mismatch),
+				r.Arch,
+				strconv.FormatUint(uint64(r.Revision), 10),
+				formatDate(r.Date, time.RFC3339),
+				buildWord(r.Build),
+				r.LastBuild,
+				res.testWord(),
+				strconv.FormatUint(uint64(res.TestsFailed), 10),
+				strconv.FormatUint(uint64(res.TestsTotal), 10),
+				r.Note,
+				r.Error,
+			})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// buildWord returns a plain word for a build indicator
+func buildWord(indicator string) string {
+	switch indicator {
+	case buildSucceeded:
+		return "ok"
+	case buildFailed:
+		return "failed"
+	case buildRunning:
+		return "building"
+	case buildPending:
+		return "pending"
+	case buildMissing:
+		return "missing"
+	default:
+		return "unknown"
+	}
+}
+
+// testWord returns a plain word for the test status of the snap
+func (res *snapResult) testWord() string {
+	switch {
+	case res.TestsFailed > 0:
+		return "failed"
+	case res.TestsRunning > 0:
+		return "running"
+	case res.testsPassed():
+		return "ok"
+	default:
+		return "unknown"
+	}
+}
+
+// formatDate formats the given time, leaving unset times blank
+func formatDate(t *time.Time, layout string) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(layout)
+}
+
+// writeFileAtomic writes to a temporary file which then replaces the given file,
+// so that readers never see a partially written file
+func writeFileAtomic(path string, write func(io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // no-op after a successful rename
+
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// isTerminal reports whether the file is a terminal
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFitWidths(t *testing.T) {
+	cols := []column{
+		{header: "Name", value: func(r row) any { return r.displayName() }, widthMax: 30},
+		{header: "Channel", value: func(r row) any { return r.Channel }},
+		{header: "Version", value: func(r row) any { return r.version() }, widthMax: 10},
+		{header: "Arch", value: func(r row) any { return r.Arch }},
+	}
+	name := "edgex-app-service-configurable"
+	results := []snapResult{{
+		Name: name,
+		Test: "🟢 passed 3/3",
+		Rows: []row{{Name: name, Channel: "latest/stable", Version: "3.1.0", Arch: "amd64", track: "latest"}},
+	}}
+
+	tests := []struct {
+		name     string
+		maxWidth int
+		want     []int
+	}{
+		// the footer of the health summary is the widest cell of the Version and Arch columns
+		{"no limit", 0, []int{30, 13, 10, 19}},
+		{"fitting", 100, []int{30, 13, 10, 19}},
+		{"shrinking the widest columns", 70, []int{17, 13, 10, 17}},
+		{"down to the minimum width", 20, []int{8, 8, 8, 8}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fitWidths(cols, results, tt.maxWidth); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderJSONUnknownDates(t *testing.T) {
+	released := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	results := []snapResult{
+		{Name: "edgexfoundry", Rows: []row{{Name: "edgexfoundry", Channel: "latest/stable", Date: &released}}},
+		{Name: "edgex-ui", Rows: []row{{Name: "edgex-ui", Channel: "latest/stable"}}},
+	}
+	var b bytes.Buffer
+	if err := renderNDJSON(&b, results, released); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	if !strings.Contains(lines[0], `"date":"2024-01-02T03:04:05Z"`) {
+		t.Errorf("no release date in %s", lines[0])
+	}
+	if strings.Contains(lines[1], `"date"`) {
+		t.Errorf("unknown release date in %s", lines[1])
+	}
+
+	b.Reset()
+	if err := renderJSON(&b, results[1:], renderOptions{generatedAt: released}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), `"date"`) || strings.Contains(b.String(), "0001-01-01") {
+		t.Errorf("unknown release date in %s", b.String())
+	}
+}
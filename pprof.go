@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	_ "net/http/pprof" // registers the profiles on http.DefaultServeMux
+	"os"
+)
+
+// hiddenFlags are left out of the usage, e.g. the flags meant for debugging the tool itself
+var hiddenFlags = set{"pprof": true}
+
+// usage prints the flags like flag.PrintDefaults, leaving out the hidden ones
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage of %s:\n", os.Args[0])
+	shown := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	shown.SetOutput(out)
+	flag.VisitAll(func(f *flag.Flag) {
+		if hiddenFlags[f.Name] {
+			return
+		}
+		shown.Var(f.Value, f.Name, f.Usage)
+		// the value may already have been parsed
+		shown.Lookup(f.Name).DefValue = f.DefValue
+	})
+	shown.PrintDefaults()
+}
+
+// startPprof serves the runtime profiles of net/http/pprof on the given address, e.g. localhost:6060,
+// in the background for the duration of the run
+func startPprof(addr string) {
+	infof("Serving pprof on %s/debug/pprof/", addr)
+	go func() {
+		// the status server has its own mux, so the default one only serves the profiles
+		if err := http.ListenAndServe(addr, nil); err != nil {
+			errorf("Error serving pprof: %s", err)
+		}
+	}()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/canonical/edgex-snap-info/snapinfo"
+)
+
+// retryTransport retries requests that failed due to network errors or
+// temporary server errors, backing off exponentially between attempts.
+// It never waits beyond the deadline of the request context, which includes the client timeout.
+type retryTransport struct {
+	next     http.RoundTripper
+	attempts int
+	backoff  time.Duration
+	// jitter is the fraction of each backoff that is randomized, from 0 to 1, so that
+	// the requests failing together, e.g. after an upstream blip, aren't retried together
+	jitter float64
+	// maxTotal is the number of retries shared across all requests, e.g. of all snaps, or 0 for no limit,
+	// so that a widespread outage fails the requests instead of retrying each of them fully
+	maxTotal int64
+	retried  atomic.Int64
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	backoff := t.backoff
+	for attempt := 1; ; attempt++ {
+		res, err := t.next.RoundTrip(req)
+		if attempt >= t.attempts || ctx.Err() != nil || !retryable(res, err) {
+			return res, err
+		}
+
+		// give up if waiting would exceed the deadline
+		wait := t.jittered(backoff)
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return res, err
+		}
+		if !t.takeRetry() {
+			return res, err
+		}
+
+		if err != nil {
+			with("url", req.URL.Redacted(), "error", err.Error()).infof("🔁 Retrying %s in %s after error: %s", req.URL.Redacted(), wait, err)
+		} else {
+			with("url", req.URL.Redacted(), "status", res.StatusCode).infof("🔁 Retrying %s in %s after status: %s", req.URL.Redacted(), wait, res.Status)
+			snapinfo.CloseBody(res)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// takeRetry takes a retry from the budget shared across all requests, reporting whether one was left
+func (t *retryTransport) takeRetry() bool {
+	if t.maxTotal <= 0 {
+		return true
+	}
+	n := t.retried.Add(1)
+	if n == t.maxTotal+1 {
+		with("max_total_retries", t.maxTotal).infof("🔁 All %d retries used up, no longer retrying the failed requests", t.maxTotal)
+	}
+	return n <= t.maxTotal
+}
+
+// resetRetries restores the whole budget of retries, e.g. for the next run
+func (t *retryTransport) resetRetries() {
+	t.retried.Store(0)
+}
+
+// jittered randomizes the jitter fraction of the backoff, spreading the waits
+// between backoff*(1-jitter) and backoff*(1+jitter)
+func (t *retryTransport) jittered(backoff time.Duration) time.Duration {
+	if t.jitter <= 0 {
+		return backoff
+	}
+	return time.Duration(float64(backoff) * (1 + t.jitter*(2*rand.Float64()-1))).Round(time.Millisecond)
+}
+
+// retryable reports whether a request with the given outcome should be retried
+func retryable(res *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch res.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
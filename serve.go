@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// serve runs an HTTP server, collecting the results of the snaps on each request for /status.json,
+// one request at a time, until the context is cancelled
+func (c *collector) serve(ctx context.Context, addr string, conf *config, names []string, sortBy string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/status.json", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		opts := renderOptions{generatedAt: time.Now().UTC().Truncate(time.Second)}
+		results := c.collectAll(r.Context(), conf, names)
+		for _, res := range results {
+			sortRows(res.Rows, sortBy)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := renderJSON(w, results, opts); err != nil {
+			errorf("Error writing the status: %s", err)
+		}
+	})
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+
+	infof("Serving the status on %s", addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
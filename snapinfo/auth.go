@@ -0,0 +1,49 @@
+package snapinfo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// StoreAuthorization returns the Authorization header for the snap store
+// from the credentials exported with `snapcraft export-login`
+func StoreAuthorization(credentials []byte) (string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(credentials)))
+	if err != nil {
+		return "", fmt.Errorf("credentials are not base64-encoded: %w", err)
+	}
+
+	var c struct {
+		Type  string          `json:"t"`
+		Value json.RawMessage `json:"v"`
+	}
+	if err := json.Unmarshal(decoded, &c); err != nil {
+		return "", fmt.Errorf("error decoding credentials: %w", err)
+	}
+
+	switch c.Type {
+	case "u1-macaroon":
+		var v struct {
+			Root      string `json:"r"`
+			Discharge string `json:"d"`
+		}
+		if err := json.Unmarshal(c.Value, &v); err != nil {
+			return "", fmt.Errorf("error decoding credentials: %w", err)
+		}
+		if v.Root == "" || v.Discharge == "" {
+			return "", errors.New("credentials lack the root or discharge macaroon")
+		}
+		return fmt.Sprintf(`Macaroon root="%s", discharge="%s"`, v.Root, v.Discharge), nil
+	case "macaroon":
+		var v string
+		if err := json.Unmarshal(c.Value, &v); err != nil {
+			return "", fmt.Errorf("error decoding credentials: %w", err)
+		}
+		return "Macaroon " + v, nil
+	default:
+		return "", fmt.Errorf("unsupported credentials type %q", c.Type)
+	}
+}
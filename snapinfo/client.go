@@ -0,0 +1,80 @@
+// Package snapinfo queries the snap store, Launchpad and GitHub for the releases,
+// builds and test runs of snaps.
+package snapinfo
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Default base URLs of the services
+const (
+	DefaultSnapStoreURL = "https://api.snapcraft.io"
+	DefaultLaunchpadURL = "https://api.launchpad.net"
+	DefaultGithubURL    = "https://api.github.com"
+)
+
+// Client queries the services, with settings shared across snaps.
+// It is safe for concurrent use once configured.
+type Client struct {
+	HTTPClient *http.Client
+	// Base URLs of the services, without a trailing slash
+	SnapStoreURL, LaunchpadURL, GithubURL string
+	// Series is the device series sent to the snap store
+	Series string
+	// DeviceArch is the optional device architecture sent to the snap store
+	DeviceArch string
+	// StoreAuth is the optional Authorization header for the snap store, e.g. from StoreAuthorization,
+	// to query private snaps
+	StoreAuth string
+	// GithubToken is optional; without it, the anonymous rate limit applies.
+	// It is only sent to GithubURL.
+	GithubToken string
+	// GithubTokens are the optional tokens of other GitHub hosts, e.g. GitHub Enterprise instances,
+	// keyed by the host of their API URL
+	GithubTokens map[string]string
+	// GithubEvent and GithubBranch filter the workflow runs; either may be empty to not filter
+	GithubEvent, GithubBranch string
+	// LaunchpadPages is the maximum number of build pages fetched per snap
+	LaunchpadPages int
+	// GithubRuns is the number of most recent workflow runs fetched per project,
+	// over several pages when more than GitHub's maximum of 100 per page
+	GithubRuns int
+}
+
+// NewClient returns a client for the public services, using the given HTTP client
+func NewClient(httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		HTTPClient:     httpClient,
+		SnapStoreURL:   DefaultSnapStoreURL,
+		LaunchpadURL:   DefaultLaunchpadURL,
+		GithubURL:      DefaultGithubURL,
+		GithubEvent:    "pull_request",
+		Series:         "16",
+		LaunchpadPages: 5,
+		GithubRuns:     10,
+	}
+}
+
+// CloseBody drains and closes the response body so that the connection can be reused
+func CloseBody(res *http.Response) {
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+}
+
+// checkStatus returns an error for a non-2xx response, including a snippet of the body
+func checkStatus(res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+
+	const maxSnippet = 200
+	body, _ := io.ReadAll(io.LimitReader(res.Body, maxSnippet))
+	snippet := strings.Join(strings.Fields(string(body)), " ")
+	return fmt.Errorf("unexpected response status from %s: %s: %s", res.Request.URL.Host, res.Status, snippet)
+}
@@ -0,0 +1,127 @@
+package snapinfo
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// trackedBody records whether a response body was read to the end and closed
+type trackedBody struct {
+	io.ReadCloser
+	drained, closed bool
+}
+
+func (b *trackedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.drained = true
+	}
+	return n, err
+}
+
+func (b *trackedBody) Close() error {
+	b.closed = true
+	return b.ReadCloser.Close()
+}
+
+// trackingTransport wraps the bodies of the responses to check them after the requests
+type trackingTransport struct {
+	mu     sync.Mutex
+	bodies []*trackedBody
+}
+
+func (t *trackingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body := &trackedBody{ReadCloser: res.Body}
+	t.mu.Lock()
+	t.bodies = append(t.bodies, body)
+	t.mu.Unlock()
+	res.Body = body
+	return res, nil
+}
+
+func (t *trackingTransport) check(tb testing.TB) {
+	tb.Helper()
+	if len(t.bodies) == 0 {
+		tb.Fatal("no responses")
+	}
+	for i, body := range t.bodies {
+		if !body.drained || !body.closed {
+			tb.Errorf("response %d: drained %t, closed %t", i, body.drained, body.closed)
+		}
+	}
+}
+
+func TestCloseBody(t *testing.T) {
+	body := &trackedBody{ReadCloser: io.NopCloser(strings.NewReader("unread"))}
+	CloseBody(&http.Response{Body: body})
+	if !body.drained || !body.closed {
+		t.Errorf("drained %t, closed %t", body.drained, body.closed)
+	}
+}
+
+func TestQueriesCloseBody(t *testing.T) {
+	// trailing data left over by the JSON decoder
+	padding := "\n" + strings.Repeat(" ", 4096)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/fail/") {
+			http.Error(w, "failed"+padding, http.StatusInternalServerError)
+			return
+		}
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/v2/snaps/info/"):
+			io.WriteString(w, `{"channel-map": []}`+padding)
+		case strings.HasSuffix(r.URL.Path, "/builds"), r.URL.Path == "/devel/+snaps":
+			io.WriteString(w, `{"entries": []}`+padding)
+		case strings.HasSuffix(r.URL.Path, "/runs"):
+			io.WriteString(w, `{"workflow_runs": []}`+padding)
+		default:
+			io.WriteString(w, `{}`+padding)
+		}
+	}))
+	defer srv.Close()
+
+	queries := map[string]func(ctx context.Context, c *Client) error{
+		"QuerySnapStore": func(ctx context.Context, c *Client) error {
+			_, err := c.QuerySnapStore(ctx, "edgexfoundry")
+			return err
+		},
+		"QueryLaunchpad": func(ctx context.Context, c *Client) error {
+			_, err := c.QueryLaunchpad(ctx, "canonical-edgex", "edgexfoundry", nil)
+			return err
+		},
+		"QueryLaunchpadRecipes": func(ctx context.Context, c *Client) error {
+			_, err := c.QueryLaunchpadRecipes(ctx, "canonical-edgex")
+			return err
+		},
+		"PingLaunchpad": func(ctx context.Context, c *Client) error {
+			return c.PingLaunchpad(ctx)
+		},
+		"QueryGithub": func(ctx context.Context, c *Client) error {
+			_, err := c.QueryGithub(ctx, "edgexfoundry/edgex-go")
+			return err
+		},
+	}
+	for name, query := range queries {
+		for _, base := range []string{srv.URL, srv.URL + "/fail"} {
+			t.Run(name+strings.TrimPrefix(base, srv.URL), func(t *testing.T) {
+				transport := &trackingTransport{}
+				c := NewClient(&http.Client{Transport: transport})
+				c.SnapStoreURL, c.LaunchpadURL, c.GithubURL = base, base, base
+				fail := base != srv.URL
+				if err := query(context.Background(), c); (err != nil) != fail {
+					t.Errorf("unexpected error: %v", err)
+				}
+				transport.check(t)
+			})
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package snapinfo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Names of the queried services, used in the errors and latencies of the results
+const (
+	ServiceSnapStore = "snapstore"
+	ServiceLaunchpad = "launchpad"
+	ServiceGithub    = "github"
+)
+
+// MaxRetryAfter is the longest wait for a GitHub secondary rate limit before giving up on the snap
+const MaxRetryAfter = time.Minute
+
+// Config lists the snaps to collect, with settings shared across snaps
+type Config struct {
+	Snaps []Snap
+	// Concurrency is the number of snaps queried in parallel, at least 1
+	Concurrency int
+	// Workflow is the name of the GitHub workflow running the tests, for the snaps that set neither
+	// a workflow name nor a workflow file
+	Workflow string
+	// Started is optionally called when the collection of a snap starts, e.g. to show the progress
+	Started func(snap Snap)
+	// Waiting is optionally called before waiting for a GitHub secondary rate limit to retry,
+	// or when the wait is too long and the snap is given up on
+	Waiting func(snap Snap, wait time.Duration, retrying bool)
+}
+
+// Snap is a snap to collect, with where to find its builds and test runs
+type Snap struct {
+	Name string
+	// LaunchpadOwner is the Launchpad person or team owning the snap recipe
+	LaunchpadOwner string
+	// GithubRepo is the GitHub repository running the tests, in owner/name form, or "" to not check the tests
+	GithubRepo string
+	// GithubAPI is the base URL of the GitHub API hosting the repo, or "" for the client's GithubURL
+	GithubAPI string
+	// WorkflowName is the name of the GitHub workflow running the tests, or "" for the one of the config
+	WorkflowName string
+	// WorkflowFile is the file of the GitHub workflow running the tests, e.g. snap-testing.yml,
+	// only querying its runs instead of filtering the runs by name
+	WorkflowFile string
+}
+
+// SnapResult is the status of a snap, as collected from the services.
+// A service that couldn't be queried leaves its fields empty and adds to the errors.
+type SnapResult struct {
+	Snap Snap
+	// Info is the store info of the snap, or nil when the store couldn't be queried
+	Info *SnapInfo
+	// Channels are the released revisions, in the order of the channel map, with the status of their builds
+	Channels []ChannelResult
+	// Builds are the recent builds of the snap, or nil when Launchpad couldn't be queried
+	Builds *Builds
+	// Runs are the recent workflow runs of the repo, or nil when GitHub couldn't be queried
+	Runs *Runs
+	// Tests summarizes the runs of the workflow running the tests, when the runs are known
+	Tests TestSummary
+	// Errors are those of the services that couldn't be queried
+	Errors []ServiceError
+	// Latencies are how long querying each service took, by service
+	Latencies map[string]time.Duration
+}
+
+// ChannelResult is a revision released to a channel, with the status of its builds
+type ChannelResult struct {
+	ChannelMapEntry
+	// Build is the most successful status of the builds of the revision for the architecture
+	Build BuildStatus
+}
+
+// TestSummary counts the runs of the workflow running the tests
+type TestSummary struct {
+	// Workflow is the name or file of the workflow
+	Workflow string
+	// Runs are the runs of the workflow, from newest to oldest
+	Runs   []WorkflowRun
+	Total  uint
+	Failed uint
+	// Running is the number of runs that are queued or in progress
+	Running uint
+	// FailedRuns are the runs that failed, from newest to oldest
+	FailedRuns []WorkflowRun
+}
+
+// ServiceError is the error of a service that couldn't be queried
+type ServiceError struct {
+	Service string
+	Err     error
+}
+
+func (e ServiceError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Service, e.Err)
+}
+
+func (e ServiceError) Unwrap() error {
+	return e.Err
+}
+
+// BuildStatus is the status of the builds of a released revision
+type BuildStatus string
+
+// Statuses of the builds, from the least to the most successful
+const (
+	// BuildUnknown is the status when Launchpad couldn't be queried
+	BuildUnknown BuildStatus = ""
+	// BuildMissing is the status of a revision without any known build, a publishing anomaly
+	BuildMissing   BuildStatus = "missing"
+	BuildFailed    BuildStatus = "failed"
+	BuildPending   BuildStatus = "pending"
+	BuildRunning   BuildStatus = "building"
+	BuildSucceeded BuildStatus = "built"
+)
+
+var buildPrecedence = map[BuildStatus]int{
+	BuildFailed:    1,
+	BuildPending:   2,
+	BuildRunning:   3,
+	BuildSucceeded: 4,
+}
+
+// Rank ranks the status from the least to the most successful, with the unknown and missing builds lowest
+func (s BuildStatus) Rank() int {
+	return buildPrecedence[s]
+}
+
+// BuildStatusOf returns the status of a Launchpad build state, or BuildUnknown for unknown states
+func BuildStatusOf(state string) BuildStatus {
+	switch state {
+	case "Successfully built":
+		return BuildSucceeded
+	case "Currently building", "Gathering build output", "Uploading build":
+		return BuildRunning
+	case "Needs building", "Dependency wait":
+		return BuildPending
+	case "Failed to build", "Failed to upload", "Chroot problem", "Build for superseded Source", "Cancelling build", "Cancelled build":
+		return BuildFailed
+	}
+	return BuildUnknown
+}
+
+// Query tells what a request made by Collect is for
+type Query struct {
+	Snap    string
+	Service string
+}
+
+type queryKey struct{}
+
+// QueryFromContext returns what the request with the given context is for, if made by Collect,
+// e.g. for a transport to tell the requests of the snaps and services apart
+func QueryFromContext(ctx context.Context) (Query, bool) {
+	q, ok := ctx.Value(queryKey{}).(Query)
+	return q, ok
+}
+
+// Collect queries the services for the snaps of the config, with a bounded number of workers,
+// returning the results in the order of the config.
+// When the context is done before all snaps were scheduled, the remaining snaps are skipped
+// and the collected results are returned with the error of the context.
+func (c *Client) Collect(ctx context.Context, cfg Config) ([]SnapResult, error) {
+	results := make([]SnapResult, len(cfg.Snaps))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < max(cfg.Concurrency, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if cfg.Started != nil {
+					cfg.Started(cfg.Snaps[i])
+				}
+				results[i] = c.collectSnap(ctx, cfg, cfg.Snaps[i])
+			}
+		}()
+	}
+	var err error
+schedule:
+	for i := range cfg.Snaps {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			err = ctx.Err()
+			break schedule
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	// drop the snaps that were never scheduled
+	collected := results[:0]
+	for _, res := range results {
+		if res.Snap.Name != "" {
+			collected = append(collected, res)
+		}
+	}
+	return collected, err
+}
+
+// collectSnap queries all services for the given snap.
+// Errors are recorded in the result so that the remaining services and snaps can still be queried.
+func (c *Client) collectSnap(ctx context.Context, cfg Config, snap Snap) SnapResult {
+	result := SnapResult{Snap: snap, Latencies: make(map[string]time.Duration)}
+	var (
+		mu                                    sync.Mutex
+		snapStoreErr, launchpadErr, githubErr error
+	)
+	g, ctx := errgroup.WithContext(ctx)
+	timed := func(service string, query func(ctx context.Context)) {
+		start := time.Now()
+		query(context.WithValue(ctx, queryKey{}, Query{Snap: snap.Name, Service: service}))
+		mu.Lock()
+		result.Latencies[service] = time.Since(start)
+		mu.Unlock()
+	}
+
+	// All services are queried concurrently. Launchpad only waits for the store after its first page,
+	// since the released revisions tell how many more build pages to fetch.
+	// A failed service doesn't cancel the others: the errors are recorded below instead of being returned to the group.
+	stored := make(chan struct{})
+	g.Go(func() error {
+		defer close(stored)
+		timed(ServiceSnapStore, func(ctx context.Context) {
+			result.Info, snapStoreErr = c.QuerySnapStore(ctx, snap.Name)
+		})
+		return nil
+	})
+	g.Go(func() error {
+		wanted := func() map[uint]bool {
+			<-stored
+			revisions := make(map[uint]bool)
+			if result.Info != nil {
+				for _, cm := range result.Info.ChannelMap {
+					revisions[cm.Revision] = true
+				}
+			}
+			return revisions
+		}
+		timed(ServiceLaunchpad, func(ctx context.Context) {
+			result.Builds, launchpadErr = c.queryLaunchpad(ctx, snap.LaunchpadOwner, snap.Name, wanted)
+		})
+		return nil
+	})
+	g.Go(func() error {
+		if snap.GithubRepo == "" {
+			return nil
+		}
+		timed(ServiceGithub, func(ctx context.Context) {
+			result.Runs, githubErr = c.queryGithubRetrying(ctx, cfg, snap)
+		})
+		return nil
+	})
+	g.Wait()
+
+	for _, e := range []ServiceError{
+		{ServiceSnapStore, snapStoreErr},
+		{ServiceLaunchpad, launchpadErr},
+		{ServiceGithub, githubErr},
+	} {
+		if e.Err != nil {
+			result.Errors = append(result.Errors, e)
+		}
+	}
+	if result.Runs != nil {
+		result.Tests = summarizeTests(result.Runs, snap, cfg.Workflow)
+	}
+	if result.Info != nil {
+		status := make(map[buildKey]BuildStatus)
+		if launchpadErr == nil {
+			status = buildStatuses(result.Builds)
+		}
+		for _, cm := range result.Info.ChannelMap {
+			build := BuildUnknown
+			if launchpadErr == nil {
+				build = buildStatusOf(status, cm.Revision, cm.Channel.Architecture)
+			}
+			result.Channels = append(result.Channels, ChannelResult{ChannelMapEntry: cm, Build: build})
+		}
+	}
+	return result
+}
+
+// buildKey identifies the builds of a revision for an architecture
+type buildKey struct {
+	revision uint
+	arch     string
+}
+
+// buildStatuses returns the status of the most successful build of each revision and architecture.
+// Builds without a revision are skipped, because:
+// - the build or artifact upload has failed (an actual failure)
+// - the build is too old and not returned in the query
+// - the build or artifact upload is pending
+func buildStatuses(builds *Builds) map[buildKey]BuildStatus {
+	status := make(map[buildKey]BuildStatus)
+	for _, b := range builds.Entries {
+		if b.StoreUploadRevision == nil {
+			continue
+		}
+		key := buildKey{*b.StoreUploadRevision, b.ArchTag}
+		if s := BuildStatusOf(b.BuildState); s.Rank() > status[key].Rank() {
+			status[key] = s
+		}
+	}
+	return status
+}
+
+// buildStatusOf returns the status of the builds of the revision for the architecture,
+// or else of the builds without an architecture tag, or else BuildMissing
+func buildStatusOf(status map[buildKey]BuildStatus, revision uint, arch string) BuildStatus {
+	if s, found := status[buildKey{revision, arch}]; found {
+		return s
+	}
+	if s, found := status[buildKey{revision, ""}]; found {
+		return s
+	}
+	return BuildMissing
+}
+
+// summarizeTests counts the runs of the workflow of the snap, or else of the given workflow name
+func summarizeTests(runs *Runs, snap Snap, workflow string) TestSummary {
+	if snap.WorkflowName != "" {
+		workflow = snap.WorkflowName
+	}
+	if snap.WorkflowFile != "" {
+		workflow = snap.WorkflowFile
+	}
+	tests := TestSummary{Workflow: workflow}
+	for _, run := range runs.WorkflowRuns {
+		// the runs of a workflow file are all of that workflow
+		if snap.WorkflowFile == "" && run.Name != workflow {
+			continue
+		}
+		tests.Runs = append(tests.Runs, run)
+		tests.Total++
+		if !run.Completed() {
+			tests.Running++
+		}
+		if run.Conclusion == "failure" {
+			tests.Failed++
+			tests.FailedRuns = append(tests.FailedRuns, run)
+		}
+	}
+	return tests
+}
+
+// queryGithubRetrying queries the workflow runs of the snap.
+// After a secondary rate limit, it waits as requested and retries once, unless the wait is too long
+// or would exceed the deadline of the context.
+func (c *Client) queryGithubRetrying(ctx context.Context, cfg Config, snap Snap) (*Runs, error) {
+	api := c.GithubURL
+	if snap.GithubAPI != "" {
+		api = strings.TrimSuffix(snap.GithubAPI, "/")
+	}
+	runs, err := c.QueryGithubWorkflow(ctx, api, snap.GithubRepo, snap.WorkflowFile)
+	var rateLimited *RateLimitError
+	if !errors.As(err, &rateLimited) || !rateLimited.Secondary {
+		return runs, err
+	}
+
+	wait := rateLimited.RetryAfter
+	if deadline, ok := ctx.Deadline(); wait > MaxRetryAfter || (ok && !time.Now().Add(wait).Before(deadline)) {
+		if cfg.Waiting != nil {
+			cfg.Waiting(snap, wait, false)
+		}
+		return nil, err
+	}
+	if cfg.Waiting != nil {
+		cfg.Waiting(snap, wait, true)
+	}
+	timer := time.NewTimer(wait)
+	select {
+	case <-ctx.Done():
+		timer.Stop()
+		return nil, ctx.Err()
+	case <-timer.C:
+	}
+	return c.QueryGithubWorkflow(ctx, api, snap.GithubRepo, snap.WorkflowFile)
+}
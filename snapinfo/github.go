@@ -0,0 +1,264 @@
+package snapinfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// githubMaxPerPage is the maximum page size of the GitHub API
+const githubMaxPerPage = 100
+
+// githubMinRetryAfter is the wait after a secondary rate limit when the response doesn't tell how long to wait,
+// as recommended by GitHub
+const githubMinRetryAfter = time.Minute
+
+// Runs is a list of GitHub workflow runs, from newest to oldest
+type Runs struct {
+	WorkflowRuns []WorkflowRun `json:"workflow_runs"`
+	Message      string
+	// RateLimit tells when the rate limit resets, once the response used up the last request
+	RateLimit string `json:"-"`
+}
+
+// WorkflowRun is a single run of a GitHub workflow
+type WorkflowRun struct {
+	Name string
+	// Status is e.g. queued, in_progress or completed; the conclusion is only set once completed
+	Status       string
+	Conclusion   string
+	DisplayTitle string `json:"display_title"`
+	HTMLURL      string `json:"html_url"`
+	// HeadSHA is the commit the run is on
+	HeadSHA   string    `json:"head_sha"`
+	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt is when the run last changed, i.e. when it completed once completed
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// QueryGithub queries the workflow runs of the given project, in owner/name form,
+// triggered by the client's event and on the client's branch, when set
+func (c *Client) QueryGithub(ctx context.Context, project string) (*Runs, error) {
+	return c.QueryGithubAt(ctx, c.GithubURL, project)
+}
+
+// QueryGithubAt is like QueryGithub, using the API of another GitHub host, e.g. GitHub Enterprise,
+// authenticated with the token of that host from GithubTokens
+func (c *Client) QueryGithubAt(ctx context.Context, baseURL, project string) (*Runs, error) {
+	return c.QueryGithubWorkflow(ctx, baseURL, project, "")
+}
+
+// QueryGithubWorkflow is like QueryGithubAt, only querying the runs of the given workflow file,
+// e.g. snap-testing.yml, or of all workflows when empty
+func (c *Client) QueryGithubWorkflow(ctx context.Context, baseURL, project, workflowFile string) (*Runs, error) {
+	runsURL := fmt.Sprintf("%s/repos/%s/actions/runs", baseURL, project)
+	if workflowFile != "" {
+		runsURL = fmt.Sprintf("%s/repos/%s/actions/workflows/%s/runs", baseURL, project, url.PathEscape(workflowFile))
+	}
+	wanted := c.GithubRuns
+	if wanted <= 0 {
+		wanted = 10
+	}
+	query := url.Values{"per_page": {strconv.Itoa(min(wanted, githubMaxPerPage))}}
+	if c.GithubEvent != "" {
+		query.Set("event", c.GithubEvent)
+	}
+	if c.GithubBranch != "" {
+		query.Set("branch", c.GithubBranch)
+	}
+
+	var runs Runs
+	for page := 1; ; page++ {
+		if page > 1 {
+			query.Set("page", strconv.Itoa(page))
+		}
+		r, err := c.queryGithubPage(ctx, baseURL, runsURL, query)
+		if err != nil {
+			return nil, err
+		}
+		runs.WorkflowRuns = append(runs.WorkflowRuns, r.WorkflowRuns...)
+		runs.Message, runs.RateLimit = r.Message, r.RateLimit
+		// a short page is the last one
+		if len(runs.WorkflowRuns) >= wanted || len(r.WorkflowRuns) < githubMaxPerPage || r.RateLimit != "" {
+			break
+		}
+	}
+	if len(runs.WorkflowRuns) > wanted {
+		runs.WorkflowRuns = runs.WorkflowRuns[:wanted]
+	}
+	return &runs, nil
+}
+
+// queryGithubPage queries a single page of workflow runs
+func (c *Client) queryGithubPage(ctx context.Context, baseURL, runsURL string, query url.Values) (*Runs, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, runsURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if token := c.githubToken(baseURL, req.URL.Host); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer CloseBody(res)
+
+	rateLimit, limited := githubRateLimit(res.Header)
+	if res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusTooManyRequests {
+		if res.Header.Get("Retry-After") != "" {
+			retryAfter := githubRetryAfter(ctx, res.Header, time.Now())
+			return nil, &RateLimitError{
+				Secondary:  true,
+				RetryAfter: retryAfter,
+				msg:        fmt.Sprintf("GitHub secondary rate limit hit, retry after %s", retryAfter),
+			}
+		}
+		if limited {
+			return nil, &RateLimitError{msg: rateLimit}
+		}
+	}
+
+	if err := checkStatus(res); err != nil {
+		return nil, err
+	}
+
+	var r Runs
+	err = json.NewDecoder(res.Body).Decode(&r)
+	if err != nil {
+		return nil, err
+	}
+	r.RateLimit = rateLimit
+
+	// log.Println("Github workflow runs:", r)
+
+	return &r, nil
+}
+
+// RateLimit is the core rate limit of the GitHub API for the client's token, or for its IP address without a token
+type RateLimit struct {
+	Limit, Remaining int
+	Reset            time.Time
+}
+
+// QueryGithubRateLimit queries the rate limit of the client's GitHub API, without counting against it
+func (c *Client) QueryGithubRateLimit(ctx context.Context) (*RateLimit, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.GithubURL+"/rate_limit", nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := c.githubToken(c.GithubURL, req.URL.Host); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer CloseBody(res)
+
+	if err := checkStatus(res); err != nil {
+		return nil, err
+	}
+
+	var r struct {
+		Resources struct {
+			Core struct {
+				Limit, Remaining int
+				Reset            int64
+			}
+		}
+	}
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+	core := r.Resources.Core
+	return &RateLimit{Limit: core.Limit, Remaining: core.Remaining, Reset: time.Unix(core.Reset, 0)}, nil
+}
+
+// githubToken returns the token for the given API URL and its host,
+// so that a token is never sent to another host than it belongs to
+func (c *Client) githubToken(baseURL, host string) string {
+	if token, found := c.GithubTokens[host]; found {
+		return token
+	}
+	if baseURL == c.GithubURL {
+		return c.GithubToken
+	}
+	return ""
+}
+
+// RateLimitError is returned when a GitHub rate limit has been hit
+type RateLimitError struct {
+	// Secondary is set for the secondary rate limits, which GitHub applies to bursts of requests
+	Secondary bool
+	// RetryAfter is how long GitHub asks to wait before retrying after a secondary rate limit
+	RetryAfter time.Duration
+	msg        string
+}
+
+func (e *RateLimitError) Error() string {
+	return e.msg
+}
+
+// ShortSHA returns the abbreviated commit the run is on
+func (r *WorkflowRun) ShortSHA() string {
+	if len(r.HeadSHA) > 7 {
+		return r.HeadSHA[:7]
+	}
+	return r.HeadSHA
+}
+
+// Completed reports whether the run has finished, i.e. it is neither queued nor in progress
+func (r *WorkflowRun) Completed() bool {
+	return r.Status == "" || r.Status == "completed"
+}
+
+// githubRateLimit reports whether the rate limit has been hit,
+// with a message telling when it resets, based on the response headers
+func githubRateLimit(h http.Header) (string, bool) {
+	if h.Get("X-RateLimit-Remaining") != "0" {
+		return "", false
+	}
+
+	reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return "GitHub primary rate limit hit", true
+	}
+	resetAt := time.Unix(reset, 0)
+	minutes := int(math.Ceil(time.Until(resetAt).Minutes()))
+	if minutes < 0 {
+		minutes = 0
+	}
+	return fmt.Sprintf("GitHub primary rate limit hit, resets in %dm (at %s)", minutes, resetAt.Format("15:04")), true
+}
+
+// githubRetryAfter returns how long to wait after a secondary rate limit, from the Retry-After header
+// in seconds or as an HTTP date, else from the reset time of the rate limit, else githubMinRetryAfter.
+// The wait is capped at the deadline of the context.
+func githubRetryAfter(ctx context.Context, h http.Header, now time.Time) time.Duration {
+	wait := githubMinRetryAfter
+	after := h.Get("Retry-After")
+	if seconds, err := strconv.Atoi(after); err == nil && seconds >= 0 {
+		wait = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(after); err == nil {
+		wait = at.Sub(now)
+	} else if reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		wait = time.Unix(reset, 0).Sub(now)
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	if deadline, ok := ctx.Deadline(); ok && wait > deadline.Sub(now) {
+		wait = deadline.Sub(now)
+	}
+	return wait
+}
@@ -0,0 +1,50 @@
+package snapinfo
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestGithubRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name    string
+		headers map[string]string
+		// deadline is the time left before the deadline of the context, or 0 for none
+		deadline time.Duration
+		want     time.Duration
+	}{
+		{"seconds", map[string]string{"Retry-After": "30"}, 0, 30 * time.Second},
+		{"zero seconds", map[string]string{"Retry-After": "0"}, 0, 0},
+		{"date", map[string]string{"Retry-After": now.Add(45 * time.Second).Format(http.TimeFormat)}, 0, 45 * time.Second},
+		{"past date", map[string]string{"Retry-After": now.Add(-time.Hour).Format(http.TimeFormat)}, 0, 0},
+		{"rate limit reset", map[string]string{
+			"Retry-After":       "soon",
+			"X-RateLimit-Reset": strconv.FormatInt(now.Add(20*time.Second).Unix(), 10),
+		}, 0, 20 * time.Second},
+		{"unparsable", map[string]string{"Retry-After": "soon"}, 0, githubMinRetryAfter},
+		{"negative seconds", map[string]string{"Retry-After": "-5"}, 0, githubMinRetryAfter},
+		{"capped at the deadline", map[string]string{"Retry-After": "30"}, 10 * time.Second, 10 * time.Second},
+		{"before the deadline", map[string]string{"Retry-After": "30"}, time.Minute, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := make(http.Header)
+			for k, v := range tt.headers {
+				h.Set(k, v)
+			}
+			ctx := context.Background()
+			if tt.deadline != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithDeadline(ctx, now.Add(tt.deadline))
+				defer cancel()
+			}
+			if got := githubRetryAfter(ctx, h, now); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
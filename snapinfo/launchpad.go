@@ -0,0 +1,152 @@
+package snapinfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Builds is a list of snap recipe builds, from newest to oldest
+type Builds struct {
+	Entries            []Build
+	NextCollectionLink string `json:"next_collection_link"`
+}
+
+// Build is a single snap recipe build
+type Build struct {
+	Title               string
+	StoreUploadRevision *uint `json:"store_upload_revision"`
+	BuildState          string
+	BuildLogURL         string     `json:"build_log_url"`
+	DateStarted         *time.Time `json:"date_started"`
+	DateBuilt           *time.Time `json:"datebuilt"`
+	// ArchTag is the architecture the snap was built for, e.g. amd64
+	ArchTag string `json:"arch_tag"`
+}
+
+// Duration returns how long the build took, if it has finished
+func (b *Build) Duration() (time.Duration, bool) {
+	if b.DateStarted == nil || b.DateBuilt == nil {
+		return 0, false
+	}
+	return b.DateBuilt.Sub(*b.DateStarted), true
+}
+
+// QueryLaunchpad queries the builds of the given project, owned by the given person or team.
+// It follows the pagination, from newest to oldest, until a successful build has been seen
+// for each of the wanted revisions or the client's maximum number of pages has been fetched.
+func (c *Client) QueryLaunchpad(ctx context.Context, owner, projectName string, wanted map[uint]bool) (*Builds, error) {
+	return c.queryLaunchpad(ctx, owner, projectName, func() map[uint]bool { return wanted })
+}
+
+// queryLaunchpad is QueryLaunchpad, only getting the wanted revisions once the first page has been fetched,
+// so that the first page can be fetched while the revisions are still being queried
+func (c *Client) queryLaunchpad(ctx context.Context, owner, projectName string, wanted func() map[uint]bool) (*Builds, error) {
+	var all Builds
+	var missing map[uint]bool
+	pageURL := fmt.Sprintf("%s/devel/~%s/+snap/%s/builds?ws.size=10&direction=backwards&memo=0", c.LaunchpadURL, owner, projectName)
+	for page := 1; pageURL != ""; page++ {
+		builds, err := c.queryLaunchpadPage(ctx, pageURL)
+		if err != nil {
+			return nil, err
+		}
+		all.Entries = append(all.Entries, builds.Entries...)
+
+		if missing == nil {
+			missing = make(map[uint]bool)
+			for rev := range wanted() {
+				missing[rev] = true
+			}
+		}
+
+		for _, b := range builds.Entries {
+			if b.StoreUploadRevision != nil && b.BuildState == "Successfully built" {
+				delete(missing, *b.StoreUploadRevision)
+			}
+		}
+		if len(missing) == 0 || page >= c.LaunchpadPages {
+			break
+		}
+		pageURL = builds.NextCollectionLink
+	}
+
+	// log.Println("Builds:", all)
+
+	return &all, nil
+}
+
+func (c *Client) queryLaunchpadPage(ctx context.Context, pageURL string) (*Builds, error) {
+	var builds Builds
+	if err := c.getLaunchpad(ctx, pageURL, &builds); err != nil {
+		return nil, err
+	}
+	return &builds, nil
+}
+
+// getLaunchpad decodes the JSON response of a Launchpad API URL into v
+func (c *Client) getLaunchpad(ctx context.Context, pageURL string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer CloseBody(res)
+
+	if err := checkStatus(res); err != nil {
+		return err
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// Recipe is a snap recipe on Launchpad
+type Recipe struct {
+	Name string
+	// StoreName is the name of the snap in the store, when the recipe uploads to the store
+	StoreName string `json:"store_name"`
+	// GitRepositoryURL is the URL of the recipe's Git repository when hosted outside of Launchpad, e.g. on GitHub
+	GitRepositoryURL string `json:"git_repository_url"`
+}
+
+// QueryLaunchpadRecipes queries all snap recipes owned by the given person or team, following the pagination
+func (c *Client) QueryLaunchpadRecipes(ctx context.Context, owner string) ([]Recipe, error) {
+	query := url.Values{
+		"ws.op": {"findByOwner"},
+		"owner": {fmt.Sprintf("%s/devel/~%s", c.LaunchpadURL, owner)},
+	}
+	var recipes []Recipe
+	for pageURL := c.LaunchpadURL + "/devel/+snaps?" + query.Encode(); pageURL != ""; {
+		var page struct {
+			Entries            []Recipe
+			NextCollectionLink string `json:"next_collection_link"`
+		}
+		if err := c.getLaunchpad(ctx, pageURL, &page); err != nil {
+			return nil, err
+		}
+		recipes = append(recipes, page.Entries...)
+		pageURL = page.NextCollectionLink
+	}
+	return recipes, nil
+}
+
+// PingLaunchpad checks that the Launchpad API is reachable, by fetching its service root
+func (c *Client) PingLaunchpad(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.LaunchpadURL+"/devel/", nil)
+	if err != nil {
+		return err
+	}
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer CloseBody(res)
+	return checkStatus(res)
+}
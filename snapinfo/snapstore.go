@@ -0,0 +1,111 @@
+package snapinfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// infoFields are the fields requested from the store: the store only returns the base,
+// confinement and grade of the revisions when asked for them, and then only the listed fields
+var infoFields = []string{"base", "confinement", "created-at", "download", "grade", "publisher", "revision", "version"}
+
+// SnapInfo is the store info of a snap
+type SnapInfo struct {
+	ChannelMap []ChannelMapEntry `json:"channel-map"`
+	// DefaultTrack is the track the publisher set as the default, or "" when unset
+	DefaultTrack string `json:"default-track"`
+	Snap         struct {
+		Publisher Publisher
+	}
+}
+
+// Publisher is the store account publishing a snap
+type Publisher struct {
+	Username    string
+	DisplayName string `json:"display-name"`
+	// Validation is e.g. verified or unproven
+	Validation string
+}
+
+func (p Publisher) String() string {
+	if p.DisplayName == "" || p.DisplayName == p.Username {
+		return p.Username
+	}
+	return fmt.Sprintf("%s (%s)", p.DisplayName, p.Username)
+}
+
+// DefaultTrackOrLatest returns the default track, or else latest which the store uses by default
+func (info *SnapInfo) DefaultTrackOrLatest() string {
+	if info.DefaultTrack != "" {
+		return info.DefaultTrack
+	}
+	return "latest"
+}
+
+// ChannelMapEntry is a revision released to a channel
+type ChannelMapEntry struct {
+	Channel  Channel
+	Revision uint
+	Version  string
+	// Confinement is e.g. strict, classic or devmode
+	Confinement string
+	// Base is the base snap, e.g. core22
+	Base string
+	// Grade is stable or devel
+	Grade    string
+	Download struct {
+		// Size is the size of the revision in bytes, 0 when unknown
+		Size uint64
+	}
+}
+
+// Channel is a channel of a single architecture
+type Channel struct {
+	Architecture string
+	Track, Risk  string
+	ReleasedAt   time.Time `json:"released-at"`
+}
+
+// QuerySnapStore queries the store info of the given snap for the client's device series.
+// When the client has a device architecture, the store only returns the channels of that architecture.
+func (c *Client) QuerySnapStore(ctx context.Context, snapName string) (*SnapInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.SnapStoreURL+"/v2/snaps/info/"+snapName+"?fields="+strings.Join(infoFields, ","), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header = http.Header{
+		"Snap-Device-Series": {c.Series},
+	}
+	if c.DeviceArch != "" {
+		req.Header.Set("Snap-Device-Architecture", c.DeviceArch)
+	}
+	if c.StoreAuth != "" {
+		req.Header.Set("Authorization", c.StoreAuth)
+	}
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer CloseBody(res)
+
+	if err := checkStatus(res); err != nil {
+		return nil, err
+	}
+
+	var info SnapInfo
+	err = json.NewDecoder(res.Body).Decode(&info)
+	if err != nil {
+		return nil, err
+	}
+
+	// log.Println("Snap info:", info)
+
+	return &info, nil
+}
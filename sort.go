@@ -0,0 +1,123 @@
+package main
+
+import (
+	"cmp"
+	"sort"
+	"strings"
+)
+
+const (
+	sortChannel = "channel"
+	sortVersion = "version"
+	sortDate    = "date"
+)
+
+var sortKeys = []string{sortChannel, sortVersion, sortDate}
+
+// riskOrder ranks the channel risk levels from the most to the least stable, unless overridden by --channel-order
+var riskOrder = map[string]int{
+	"stable":    1,
+	"candidate": 2,
+	"beta":      3,
+	"edge":      4,
+}
+
+// parseChannelOrder parses a comma-separated list of risk levels, from the first to the last shown
+func parseChannelOrder(list string) map[string]int {
+	order := make(map[string]int)
+	for _, risk := range strings.Split(list, ",") {
+		if risk = strings.ToLower(strings.TrimSpace(risk)); risk != "" {
+			if _, found := order[risk]; !found {
+				order[risk] = len(order) + 1
+			}
+		}
+	}
+	return order
+}
+
+// lessChannel orders rows by track, then risk and then architecture
+func lessChannel(a, b row) bool {
+	if a.track != b.track {
+		return a.track < b.track
+	}
+	if a.risk != b.risk {
+		ra, rb := riskRank(a.risk), riskRank(b.risk)
+		if ra != rb {
+			return ra < rb
+		}
+		return a.risk < b.risk
+	}
+	return a.Arch < b.Arch
+}
+
+// riskRank returns the rank of a risk level, placing unknown ones last
+func riskRank(risk string) int {
+	if r, found := riskOrder[strings.ToLower(risk)]; found {
+		return r
+	}
+	return len(riskOrder) + 1
+}
+
+// sortRows sorts the rows by the given key.
+// Version and date are sorted from newest to oldest, with ties ordered by channel.
+func sortRows(rows []row, by string) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		switch by {
+		case sortVersion:
+			if c := compareVersions(a.Version, b.Version); c != 0 {
+				return c > 0
+			}
+		case sortDate:
+			// unknown dates sort last
+			if (a.Date == nil) != (b.Date == nil) {
+				return b.Date == nil
+			}
+			if a.Date != nil && !a.Date.Equal(*b.Date) {
+				return a.Date.After(*b.Date)
+			}
+		}
+		return lessChannel(a, b)
+	})
+}
+
+// compareVersions compares versions such as 2.10.0 and 3.0.0-dev.12 part by part,
+// numerically for the runs of digits and lexically otherwise, returning -1, 0 or 1
+func compareVersions(a, b string) int {
+	for a != "" && b != "" {
+		pa, pb := versionPart(a), versionPart(b)
+		a, b = a[len(pa):], b[len(pb):]
+		if isDigit(pa[0]) && isDigit(pb[0]) {
+			na, nb := strings.TrimLeft(pa, "0"), strings.TrimLeft(pb, "0")
+			if len(na) != len(nb) {
+				return cmp.Compare(len(na), len(nb))
+			}
+			pa, pb = na, nb
+		}
+		if c := strings.Compare(pa, pb); c != 0 {
+			return c
+		}
+	}
+	// a pre-release such as 3.1.0-dev.1 comes before its release, other suffixes after it
+	if strings.HasPrefix(a, "-") {
+		return -1
+	}
+	if strings.HasPrefix(b, "-") {
+		return 1
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+// versionPart returns the leading run of digits or of other characters of a non-empty version
+func versionPart(v string) string {
+	digit := isDigit(v[0])
+	i := 1
+	for i < len(v) && isDigit(v[i]) == digit {
+		i++
+	}
+	return v[:i]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
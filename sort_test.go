@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"2.10.0", "2.9.0", 1},
+		{"2.9.0", "2.10.0", -1},
+		{"3.0.0", "3.0.0", 0},
+		{"3.0.0", "3.0", 1},
+		{"3.1.0-dev.12", "3.1.0-dev.9", 1},
+		{"3.1.0-dev.1", "3.1.0", -1},
+		{"3.1.0", "3.1.0-dev.1", 1},
+		{"3.1.0+git1", "3.1.0", 1},
+		{"02.1", "2.1", 0},
+		{"", "1.0", -1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSortRowsByVersion(t *testing.T) {
+	rows := []row{{Version: "2.9.0"}, {Version: "2.10.0"}, {Version: "2.3.1"}}
+	sortRows(rows, sortVersion)
+	for i, want := range []string{"2.10.0", "2.9.0", "2.3.1"} {
+		if rows[i].Version != want {
+			t.Errorf("row %d has version %s, want %s", i, rows[i].Version, want)
+		}
+	}
+}
+
+func TestSortRowsByDate(t *testing.T) {
+	older := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	newer := older.AddDate(0, 1, 0)
+	rows := []row{{Channel: "latest/beta"}, {Channel: "latest/stable", Date: &older}, {Channel: "latest/edge", Date: &newer}}
+	sortRows(rows, sortDate)
+	// the unknown date sorts last
+	for i, want := range []string{"latest/edge", "latest/stable", "latest/beta"} {
+		if rows[i].Channel != want {
+			t.Errorf("row %d is %s, want %s", i, rows[i].Channel, want)
+		}
+	}
+}
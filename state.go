@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// snapStatus is the last-seen status of a snap, persisted between runs
+type snapStatus struct {
+	TestsFailed   bool `json:"testsFailed"`
+	MissingBuilds bool `json:"missingBuilds"`
+	// MaxRevisions are the highest revisions seen per track/risk/arch, for --detect-rollback
+	MaxRevisions map[string]uint `json:"maxRevisions,omitempty"`
+	// Channels are the releases seen in the last run per track/risk/arch, for --since-last-run
+	Channels map[string]channelSnapshot `json:"channels,omitempty"`
+}
+
+// channelSnapshot is the release of a channel on an architecture
+type channelSnapshot struct {
+	Revision uint   `json:"revision"`
+	Version  string `json:"version"`
+}
+
+// channelKey identifies the channel and architecture of a row in the state
+func channelKey(r row) string {
+	return r.track + "/" + r.risk + "/" + r.Arch
+}
+
+func (s snapStatus) healthy() bool {
+	return !s.TestsFailed && !s.MissingBuilds
+}
+
+func statusOf(res *snapResult) snapStatus {
+	return snapStatus{
+		TestsFailed:   res.TestsFailed > 0,
+		MissingBuilds: res.missingBuilds(),
+	}
+}
+
+// state holds the last-seen status of each snap
+type state map[string]snapStatus
+
+// loadState reads the state file, which doesn't exist before the first run
+func loadState(path string) (state, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := state{}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func saveState(path string, s state) error {
+	return writeFileAtomic(path, func(w io.Writer) error {
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		return e.Encode(s)
+	})
+}
+
+// detectRollbacks notes the channels whose revision is lower than the highest one seen before,
+// and records the highest revisions in the state
+func (s state) detectRollbacks(results []snapResult) {
+	for i := range results {
+		res := &results[i]
+		status := s[res.Name]
+		if status.MaxRevisions == nil {
+			status.MaxRevisions = make(map[string]uint)
+		}
+		for _, r := range res.Rows {
+			key := channelKey(r)
+			highest, found := status.MaxRevisions[key]
+			if found && r.Revision < highest {
+				with("snap", res.Name, "channel", r.Channel, "arch", r.Arch, "revision", r.Revision).infof("⚠️ %s %s on %s was rolled back from revision %d to %d", res.Name, r.Channel, r.Arch, highest, r.Revision)
+				res.addNote("%s on %s rolled back from revision %d to %d", r.Channel, r.Arch, highest, r.Revision)
+				continue
+			}
+			status.MaxRevisions[key] = r.Revision
+		}
+		s[res.Name] = status
+	}
+}
+
+// checkRollbacks detects rollbacks against the highest revisions recorded in the state file, updating it
+func checkRollbacks(path string, results []snapResult) error {
+	s, err := loadState(path)
+	if err != nil {
+		return err
+	}
+	s.detectRollbacks(results)
+	return saveState(path, s)
+}
+
+// renderChanges prints the releases that are new or changed since the last run, and records them in the state.
+// The channels that aren't shown, e.g. due to filters, keep their last-seen release.
+func (s state) renderChanges(w io.Writer, results []snapResult) error {
+	changes := 0
+	for _, res := range results {
+		status := s[res.Name]
+		if status.Channels == nil {
+			status.Channels = make(map[string]channelSnapshot)
+		}
+		for _, r := range res.Rows {
+			key := channelKey(r)
+			prev, found := status.Channels[key]
+			cur := channelSnapshot{Revision: r.Revision, Version: r.Version}
+			status.Channels[key] = cur
+			if found && prev == cur {
+				continue
+			}
+
+			prefix := fmt.Sprintf("%s %s %s:", res.Name, r.Channel, r.Arch)
+			var err error
+			if !found {
+				_, err = fmt.Fprintf(w, "%s new, rev %d (%s)\n", prefix, cur.Revision, cur.Version)
+			} else {
+				var deltas []string
+				if prev.Revision != cur.Revision {
+					deltas = append(deltas, fmt.Sprintf("rev %d→%d", prev.Revision, cur.Revision))
+				}
+				if prev.Version != cur.Version {
+					deltas = append(deltas, fmt.Sprintf("%s→%s", prev.Version, cur.Version))
+				}
+				_, err = fmt.Fprintf(w, "%s %s\n", prefix, strings.Join(deltas, ", "))
+			}
+			if err != nil {
+				return err
+			}
+			changes++
+		}
+		s[res.Name] = status
+	}
+	if changes == 0 {
+		infof("No changes since the last run")
+	}
+	return nil
+}
+
+// transitions compares the results with the last-seen state, returning the snaps that became unhealthy,
+// those that recovered, and the next state.
+// Snaps not seen before are considered to have been healthy.
+// Snaps whose queries failed keep their last-seen status, since their current status is unknown.
+func (s state) transitions(results []snapResult) (failed, recovered []snapResult, next state) {
+	next = make(state, len(s))
+	for name, status := range s {
+		next[name] = status
+	}
+	for _, res := range results {
+		if res.Error != "" {
+			continue
+		}
+		prev, cur := s[res.Name], statusOf(&res)
+		cur.MaxRevisions, cur.Channels = prev.MaxRevisions, prev.Channels
+		switch {
+		case prev.healthy() && !cur.healthy():
+			failed = append(failed, res)
+		case !prev.healthy() && cur.healthy():
+			recovered = append(recovered, res)
+		}
+		next[res.Name] = cur
+	}
+	return failed, recovered, next
+}
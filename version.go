@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// Build metadata, injected at build time with:
+//
+//	go build -ldflags "-X main.version=<version> -X main.commit=<commit> -X main.date=<date>"
+var (
+	version = "dev"
+	commit  = "unknown"
+	date    = "unknown"
+)
+
+// versionString returns the build metadata,
+// falling back to the info embedded by the Go toolchain when not injected
+func versionString() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if version == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			version = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch {
+			case s.Key == "vcs.revision" && commit == "unknown":
+				commit = s.Value
+			case s.Key == "vcs.time" && date == "unknown":
+				date = s.Value
+			}
+		}
+	}
+	return fmt.Sprintf("edgex-snap-info %s (commit %s, built %s)", version, commit, date)
+}